@@ -0,0 +1,267 @@
+package confremote_pilot
+
+import (
+	"context"
+	"fmt"
+	"github.com/fufuzion/confremote-pilot/logger"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type batchOption struct {
+	concurrency int
+	timeout     time.Duration
+}
+
+type BatchOption func(*batchOption)
+
+// WithBatchConcurrency 同时创建的provider数量上限，默认8
+func WithBatchConcurrency(n int) BatchOption {
+	return func(o *batchOption) {
+		if n > 0 {
+			o.concurrency = n
+		}
+	}
+}
+
+// WithBatchTimeout 整个批次的截止时间，超时仍未创建完成的source视为失败，默认不限制
+func WithBatchTimeout(d time.Duration) BatchOption {
+	return func(o *batchOption) {
+		o.timeout = d
+	}
+}
+
+// BatchError 批量注册失败时返回，列出每个失败的source
+type BatchError struct {
+	Failed map[string]error
+}
+
+func (e *BatchError) Error() string {
+	keys := make([]string, 0, len(e.Failed))
+	for key := range e.Failed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	msgs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		msgs = append(msgs, key+": "+e.Failed[key].Error())
+	}
+	return fmt.Sprintf("register %d source(s) failed: %s", len(keys), strings.Join(msgs, "; "))
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, err := range e.Failed {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+type batchResult struct {
+	key string
+	s   *source
+	err error
+}
+
+// RegisterSourceBatch 并发创建一批source并一次性合并，要么全部注册成功，要么关闭已创建的provider、bridge保持不变，
+// 此时返回*BatchError；必需的source按各自的重试策略重试，仍失败时若开启WithCacheDir且有缓存则以缓存加入批次并在后台重连，
+// 连接失败的可选source不影响批次，批次成功后转为后台注册。
+// 同一批次中priority相同的source按key的字典序合并
+func (b *Bridge) RegisterSourceBatch(sources map[string]*Config, opts ...BatchOption) error {
+	o := &batchOption{concurrency: 8}
+	for _, opt := range opts {
+		opt(o)
+	}
+	keys := make([]string, 0, len(sources))
+	for key := range sources {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if err := b.checkBatch(keys); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+	results := make(chan batchResult, len(keys))
+	sem := make(chan struct{}, o.concurrency)
+	for _, key := range keys {
+		go func(key string) {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				results <- batchResult{key: key, err: ctx.Err()}
+				return
+			}
+			defer func() { <-sem }()
+			cfg := sources[key]
+			attempts := 1
+			if !cfg.Optional {
+				attempts = b.retryPolicy(cfg).MaxAttempts
+			}
+			s, err := b.createWithRetry(ctx, key, cfg, attempts)
+			results <- batchResult{key: key, s: s, err: err}
+		}(key)
+	}
+
+	created := make(map[string]*source, len(keys))
+	failed := make(map[string]error)
+	var deferred []string // 连接失败的可选source，批次成功后转为后台注册
+	fail := func(key string, err error) {
+		if sources[key].Optional && retryable(err) {
+			deferred = append(deferred, key)
+			return
+		}
+		if stale := b.staleSource(key, sources[key], err); stale != nil {
+			created[key] = stale
+			return
+		}
+		failed[key] = err
+	}
+	received := 0
+wait:
+	for received < len(keys) {
+		select {
+		case r := <-results:
+			received++
+			if r.err != nil {
+				fail(r.key, r.err)
+				continue
+			}
+			created[r.key] = r.s
+		case <-ctx.Done():
+			done := make(map[string]bool, len(keys))
+			for key := range created {
+				done[key] = true
+			}
+			for key := range failed {
+				done[key] = true
+			}
+			for _, key := range deferred {
+				done[key] = true
+			}
+			for _, key := range keys {
+				if !done[key] {
+					fail(key, fmt.Errorf("create source: %w", ctx.Err()))
+				}
+			}
+			break wait
+		}
+	}
+	if received < len(keys) {
+		go closeLate(results, len(keys)-received)
+	}
+	if len(failed) > 0 {
+		closeSources(created)
+		err := &BatchError{Failed: failed}
+		b.o.logger.Error("register batch failed", logger.KeyError, err)
+		return err
+	}
+	registered := make([]string, 0, len(created))
+	for _, key := range keys {
+		if _, ok := created[key]; ok {
+			registered = append(registered, key)
+		}
+	}
+	if err := b.addBatch(registered, created); err != nil {
+		return err
+	}
+	for _, key := range registered {
+		if s := created[key]; s.stale {
+			b.startRecover(s)
+		}
+	}
+	sort.Strings(deferred)
+	for _, key := range deferred {
+		b.o.logger.Warn("optional source unavailable, retrying in background", logger.KeySource, key, logger.KeyProvider, sources[key].Provider)
+		if err := b.RegisterSourceAsync(key, sources[key]); err != nil {
+			b.o.logger.Error("register optional source failed", logger.KeySource, key, logger.KeyError, err)
+		}
+	}
+	return nil
+}
+
+// checkBatch 批次中的key都未注册、也不在后台注册中，且bridge未关闭
+func (b *Bridge) checkBatch(keys []string) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.checkBatchLocked(keys)
+}
+
+func (b *Bridge) checkBatchLocked(keys []string) error {
+	if b.closed {
+		return ErrBridgeClosed
+	}
+	failed := make(map[string]error)
+	for _, key := range keys {
+		if b.exists(key) {
+			failed[key] = fmt.Errorf("%w, use ReplaceSource", ErrSourceExists)
+		}
+	}
+	if len(failed) > 0 {
+		return &BatchError{Failed: failed}
+	}
+	return nil
+}
+
+// addBatch 在一次合并中加入全部已创建的source，失败时关闭它们
+func (b *Bridge) addBatch(keys []string, created map[string]*source) error {
+	b.mu.Lock()
+	if err := b.checkBatchLocked(keys); err != nil {
+		b.mu.Unlock()
+		closeSources(created)
+		return err
+	}
+	before := b.Snapshot()
+	affected := make(map[string]struct{})
+	for _, key := range keys {
+		s := created[key]
+		b.refreshLocked(s)
+		b.addSource(s)
+		for k := range topKeys(s.settings) {
+			affected[k] = struct{}{}
+		}
+	}
+	if err := b.rebuild("", affected); err != nil {
+		for _, key := range keys {
+			delete(b.pvm, key)
+		}
+		b.mu.Unlock()
+		closeSources(created)
+		return err
+	}
+	b.commit(nil, before, nil)
+	for _, key := range keys {
+		b.cacheLocked(created[key])
+	}
+	b.mu.Unlock()
+	b.o.logger.Info("source batch registered", "count", len(keys))
+	return nil
+}
+
+func closeSources(sources map[string]*source) {
+	var wg sync.WaitGroup
+	for _, s := range sources {
+		wg.Add(1)
+		go func(s *source) {
+			defer wg.Done()
+			_ = s.close()
+		}(s)
+	}
+	wg.Wait()
+}
+
+// closeLate 批次超时后，关闭之后才创建完成的source
+func closeLate(results <-chan batchResult, n int) {
+	for ; n > 0; n-- {
+		if r := <-results; r.s != nil {
+			_ = r.s.close()
+		}
+	}
+}
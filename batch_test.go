@@ -0,0 +1,134 @@
+package confremote_pilot
+
+import (
+	"context"
+	"errors"
+	"github.com/fufuzion/confremote-pilot/provider"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBridge_RegisterSourceBatchAtomic(t *testing.T) {
+	b, f := newFakeBridge(t)
+	err := b.RegisterSourceBatch(map[string]*Config{
+		"a":   fakeConfig(map[string]any{"a": 1}),
+		"b":   fakeConfig(map[string]any{"b": 1}),
+		"bad": {Provider: fakeProviderType, Properties: map[string]any{"err": "boom"}},
+		"x":   {Provider: fakeProviderType, Properties: map[string]any{"err": "bang"}},
+	})
+	var berr *BatchError
+	if !errors.As(err, &berr) {
+		t.Fatalf("err = %v, want *BatchError", err)
+	}
+	if len(berr.Failed) != 2 || berr.Failed["bad"] == nil || berr.Failed["x"] == nil {
+		t.Errorf("failed = %v, want bad and x", berr.Failed)
+	}
+	for _, key := range []string{"a", "b"} {
+		if !f.get(key).isClosed() {
+			t.Errorf("provider %s not closed after failed batch", key)
+		}
+	}
+	if len(b.Layers()) != 0 || b.Generation() != 0 {
+		t.Error("failed batch must leave the bridge unchanged")
+	}
+
+	if err = b.RegisterSource("a", fakeConfig(nil)); err != nil {
+		t.Fatal(err)
+	}
+	err = b.RegisterSourceBatch(map[string]*Config{"a": fakeConfig(nil), "c": fakeConfig(nil)})
+	if !errors.Is(err, ErrSourceExists) {
+		t.Errorf("err = %v, want ErrSourceExists", err)
+	}
+	if f.get("c") != nil {
+		t.Error("no provider should be created when a key already exists")
+	}
+}
+
+func TestBridge_RegisterSourceBatchConcurrency(t *testing.T) {
+	b, _ := newFakeBridge(t)
+	create := b.factory
+	var running, peak int32
+	b.factory = func(ctx context.Context, key string, cfg *Config) (provider.Provider, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return create(ctx, key, cfg)
+	}
+	sources := make(map[string]*Config)
+	for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+		sources[key] = fakeConfig(map[string]any{key: true})
+	}
+	if err := b.RegisterSourceBatch(sources, WithBatchConcurrency(2)); err != nil {
+		t.Fatal(err)
+	}
+	if peak != 2 {
+		t.Errorf("peak concurrency = %d, want 2", peak)
+	}
+	if len(b.Layers()) != 6 || b.Get("f") != true {
+		t.Error("batch not merged")
+	}
+}
+
+func TestBridge_RegisterSourceBatchTimeout(t *testing.T) {
+	b, f := newFakeBridge(t)
+	create := b.factory
+	b.factory = func(ctx context.Context, key string, cfg *Config) (provider.Provider, error) {
+		if key == "slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		return create(ctx, key, cfg)
+	}
+	err := b.RegisterSourceBatch(map[string]*Config{
+		"fast": fakeConfig(map[string]any{"fast": 1}),
+		"slow": fakeConfig(map[string]any{"slow": 1}),
+	}, WithBatchTimeout(50*time.Millisecond))
+	var berr *BatchError
+	if !errors.As(err, &berr) || !errors.Is(berr.Failed["slow"], context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want slow to fail with deadline exceeded", err)
+	}
+	if !f.get("fast").isClosed() {
+		t.Error("fast provider not closed after timeout")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for f.get("slow") == nil || !f.get("slow").isClosed() {
+		if time.Now().After(deadline) {
+			t.Fatal("slow provider not closed after it finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(b.Layers()) != 0 {
+		t.Error("timed out batch must leave the bridge unchanged")
+	}
+}
+
+func TestBridge_RegisterSourceBatchMissedNotify(t *testing.T) {
+	b, f := newFakeBridge(t)
+	create := b.factory
+	b.factory = func(ctx context.Context, key string, cfg *Config) (provider.Provider, error) {
+		if key == "b" {
+			// 等a创建完成但尚未加入bridge，此时的变更通知找不到a
+			for f.get("a") == nil || f.get("a").loadCount() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			f.get("a").push(map[string]any{"n": 1})
+		}
+		return create(ctx, key, cfg)
+	}
+	err := b.RegisterSourceBatch(map[string]*Config{
+		"a": fakeConfig(map[string]any{"n": 0}),
+		"b": fakeConfig(map[string]any{"m": 0}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Get("n"); got != 1 {
+		t.Errorf("n = %v, want the change made before the batch was merged", got)
+	}
+}
@@ -0,0 +1,68 @@
+package confremote_pilot
+
+import (
+	"github.com/fufuzion/confremote-pilot/logger"
+	"sync/atomic"
+)
+
+// Binding 将合并视图中prefix对应的子树解码为T，并在该子树变化后自动重新解码
+type Binding[T any] struct {
+	b      *Bridge
+	prefix string
+	value  atomic.Pointer[T]
+	err    atomic.Pointer[error]
+	cancel func()
+}
+
+// Bind 按mapstructure tag将prefix对应的子树（prefix为空时为整个视图）解码为T；
+// 解码失败时保留上一次成功的值，错误可通过Err获取
+func Bind[T any](b *Bridge, prefix string) *Binding[T] {
+	bd := &Binding[T]{b: b, prefix: prefix}
+	bd.value.Store(new(T))
+	filter := Filter{}
+	if prefix != "" {
+		filter.Keys = []string{prefix}
+	}
+	bd.cancel = b.Subscribe(filter, func(ev ChangeEvent) {
+		if ev.Type != EventValidationFailed {
+			bd.reload()
+		}
+	})
+	bd.reload()
+	return bd
+}
+
+// Load 返回最近一次成功解码的值，无锁
+func (bd *Binding[T]) Load() T {
+	return *bd.value.Load()
+}
+
+// Err 返回最近一次解码的错误，成功时为nil
+func (bd *Binding[T]) Err() error {
+	if err := bd.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// Close 停止自动重新解码，Load仍返回最后的值
+func (bd *Binding[T]) Close() {
+	bd.cancel()
+}
+
+func (bd *Binding[T]) reload() {
+	v := new(T)
+	var err error
+	if bd.prefix == "" {
+		err = bd.b.Config().Unmarshal(v)
+	} else {
+		err = bd.b.Config().UnmarshalKey(bd.prefix, v)
+	}
+	if err != nil {
+		bd.b.o.logger.Warn("decode binding failed", logger.KeyPath, bd.prefix, logger.KeyError, err)
+		bd.err.Store(&err)
+		return
+	}
+	bd.value.Store(v)
+	bd.err.Store(nil)
+}
@@ -0,0 +1,44 @@
+package confremote_pilot
+
+import (
+	"testing"
+	"time"
+)
+
+type dbConfig struct {
+	Host    string        `mapstructure:"host"`
+	Port    int           `mapstructure:"port"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+func TestBind(t *testing.T) {
+	b, f := newFakeBridge(t)
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{
+		"db": map[string]any{"host": "h1", "port": 3306, "timeout": "2s"},
+	})); err != nil {
+		t.Fatal(err)
+	}
+	bd := Bind[dbConfig](b, "db")
+	defer bd.Close()
+	if got := bd.Load(); got.Host != "h1" || got.Port != 3306 || got.Timeout != 2*time.Second {
+		t.Errorf("initial = %+v", got)
+	}
+
+	f.get("a").push(map[string]any{"db": map[string]any{"host": "h2", "port": 3307}})
+	if got := bd.Load(); got.Host != "h2" || got.Port != 3307 {
+		t.Errorf("after update = %+v", got)
+	}
+
+	f.get("a").push(map[string]any{"db": map[string]any{"host": "h3", "port": "not-a-port"}})
+	if bd.Err() == nil {
+		t.Error("expected decode error")
+	}
+	if got := bd.Load(); got.Host != "h2" {
+		t.Errorf("after bad update = %+v, want previous value", got)
+	}
+
+	f.get("a").push(map[string]any{"db": map[string]any{"host": "h4", "port": 1}})
+	if bd.Err() != nil || bd.Load().Host != "h4" {
+		t.Errorf("after recovery = %+v, err = %v", bd.Load(), bd.Err())
+	}
+}
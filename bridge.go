@@ -2,72 +2,464 @@ package confremote_pilot
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/fufuzion/confremote-pilot/codec"
+	"github.com/fufuzion/confremote-pilot/logger"
 	"github.com/fufuzion/confremote-pilot/mediator"
 	"github.com/fufuzion/confremote-pilot/provider"
 	"github.com/spf13/viper"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 var bridge *Bridge
 var once sync.Once
 
 type Bridge struct {
-	ctx         context.Context
-	vp          atomic.Value
-	mu          *sync.RWMutex
-	pvm         map[string]provider.Provider
-	coordinator *mediator.Coordinator
-	hook        func(key string, msg map[string]any)
+	ctx          context.Context
+	snap         atomic.Pointer[Snapshot]
+	mu           *sync.RWMutex
+	pvm          map[string]*source
+	pending      map[string]*pendingSource // 正在后台注册的source
+	seq          uint64
+	coordinator  *mediator.Coordinator
+	hook         func(key string, msg map[string]any)
+	validators   []Validator
+	handlerMu    *sync.RWMutex
+	subscribers  []*subscriber
+	subSeq       uint64
+	errHandlers  []func(SourceError)
+	valueMu      *sync.RWMutex
+	values       map[string][]valueUpdater
+	o            *bridgeOption
+	factory      providerFactory
+	closed       bool
+	done         chan struct{}  // Close时关闭
+	stop         chan struct{}  // Close或ctx结束时关闭，事件循环与outbox随之退出
+	loop         *taskQueue     // 事件循环，见loop.go
+	outbox       *taskQueue     // hook与订阅方回调的分发
+	history      []HistoryEntry // 最近的视图，按generation升序
+	pinned       uint64         // Rollback固定的generation，为0时未固定
+	frozen       bool           // Freeze期间远端变更只暂存不合并
+	freezeReason string
+	frozenAt     time.Time
+	stageSeq     uint64     // 暂存变更的编号
+	deb          *debouncer // WithDebounce设置的bridge级防抖，未设置时为nil
 }
 
+// Instance 返回进程级单例，仅第一次调用时的ctx生效；需要多个相互独立的配置视图时使用New
 func Instance(ctx context.Context) *Bridge {
 	once.Do(func() {
-		bridge = &Bridge{
-			ctx: ctx,
-			vp:  atomic.Value{},
-			mu:  &sync.RWMutex{},
-			pvm: make(map[string]provider.Provider),
-		}
-		bridge.vp.Store(viper.New())
-		bridge.coordinator = mediator.NewCoordinator(bridge)
-
+		bridge = New(ctx)
 	})
 	return bridge
 }
 
+// New 创建一个独立的Bridge，ctx结束时其下所有provider的监听随之退出
+func New(ctx context.Context, opts ...BridgeOption) *Bridge {
+	o := &bridgeOption{
+		hookDispatch:  HookDispatchSync,
+		mergeStrategy: MergeDeep,
+		logger:        logger.Default(),
+		retry:         defaultRetryPolicy,
+		history:       defaultHistorySize,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	b := &Bridge{
+		ctx:        ctx,
+		mu:         &sync.RWMutex{},
+		pvm:        make(map[string]*source),
+		pending:    make(map[string]*pendingSource),
+		hook:       o.hook,
+		validators: o.validators,
+		handlerMu:  &sync.RWMutex{},
+		valueMu:    &sync.RWMutex{},
+		values:     make(map[string][]valueUpdater),
+		o:          o,
+		done:       make(chan struct{}),
+		loop:       newTaskQueue(),
+		outbox:     newTaskQueue(),
+	}
+	b.snap.Store(emptySnapshot)
+	b.coordinator = mediator.NewCoordinator(b)
+	b.factory = b.newProvider
+	b.deb = newDebouncer(o.debounce, o.debounceMaxWait, func(msgs map[string]map[string]any) {
+		b.enqueue(func() {
+			b.applyMany(msgs)
+		})
+	})
+	if b.deb != nil {
+		b.deb.shared = true
+	}
+	b.runLoops()
+	return b
+}
+
+// Config 返回当前合并视图的viper实例，用于兼容viper的读取方式，不得修改
 func (b *Bridge) Config() *viper.Viper {
-	return b.vp.Load().(*viper.Viper)
+	return b.snap.Load().viper()
+}
+
+// Snapshot 返回当前合并视图的不可变快照
+func (b *Bridge) Snapshot() *Snapshot {
+	return b.snap.Load()
 }
 func (b *Bridge) Get(key string) any {
-	return b.vp.Load().(*viper.Viper).Get(key)
+	return b.snap.Load().Get(key)
 }
+
+// All 返回当前合并视图完整配置树的拷贝，调用方可以修改；只读且需避免拷贝时使用Snapshot().Tree()
 func (b *Bridge) All() map[string]any {
-	return b.vp.Load().(*viper.Viper).AllSettings()
+	return deepCopyMap(b.snap.Load().Tree())
 }
 
+// SetHook 设置以source原始配置回调的hook，会替换之前设置的hook；需要多个订阅方时使用Subscribe
 func (b *Bridge) SetHook(hook func(key string, msg map[string]any)) {
+	b.handlerMu.Lock()
+	defer b.handlerMu.Unlock()
 	b.hook = hook
 }
 
+// Update 由provider的变更通知调用，只入队不阻塞，由事件循环按到达顺序应用；
+// source开启了防抖时先由debouncer合并，bridge级的窗口内多个source的通知合并为一次更新
 func (b *Bridge) Update(key string, msg map[string]any) {
-	mergeConfig := viper.New()
 	b.mu.RLock()
-	defer b.mu.RUnlock()
-	for _, pv := range b.pvm {
-		subConfig, err := pv.Load()
+	s, ok := b.pvm[key]
+	b.mu.RUnlock()
+	if ok && s.deb != nil {
+		s.deb.notify(key, msg)
+		return
+	}
+	b.enqueue(func() {
+		b.apply(key, msg)
+	})
+}
+
+// apply 重新读取key对应的source并提交新视图，在事件循环上执行；Freeze期间只暂存读取到的配置
+func (b *Bridge) apply(key string, msg map[string]any) {
+	b.mu.Lock()
+	s, ok := b.pvm[key]
+	if !ok || b.closed { // 已注销source的滞后通知
+		b.mu.Unlock()
+		return
+	}
+	if b.frozen {
+		err := b.hold(s, msg)
+		b.mu.Unlock()
 		if err != nil {
+			b.o.logger.Error("load frozen config failed", logger.KeySource, key, logger.KeyProvider, s.cfg.Provider, logger.KeyError, err)
+			var serr *SourceError
+			if errors.As(err, &serr) {
+				b.reportError(serr)
+			}
 			return
 		}
-		err = mergeConfig.MergeConfigMap(subConfig)
-		if err != nil {
-			return
+		b.o.logger.Debug("config held while frozen", logger.KeySource, key, logger.KeyProvider, s.cfg.Provider, logger.KeyRevision, s.pv.Revision())
+		return
+	}
+	before := b.Snapshot()
+	var (
+		pc  *PendingChange
+		err error
+	)
+	if len(b.o.applyPolicies) > 0 {
+		pc, err = b.stage(s, msg)
+	} else {
+		err = b.reload(s)
+	}
+	if pc != nil {
+		b.mu.Unlock()
+		b.o.logger.Info("config change staged", logger.KeySource, key, logger.KeyProvider, s.cfg.Provider, logger.KeyRevision, pc.Revision, "change", pc.ID, "mode", pc.Mode)
+		return
+	}
+	var verr *ValidationError
+	switch {
+	case errors.As(err, &verr):
+		ev := newChangeEvent(EventValidationFailed, s, before.gen)
+		b.enqueue(func() {
+			b.publishRejected(ev, before, verr)
+		})
+	case err == nil:
+		b.commit(s, before, func(*Snapshot) {
+			b.fireHook(key, msg)
+		})
+		b.cacheLocked(s)
+	}
+	b.mu.Unlock()
+	switch {
+	case verr != nil:
+		b.o.logger.Warn("config rejected by validator", logger.KeySource, key, logger.KeyProvider, s.cfg.Provider, logger.KeyRevision, s.pv.Revision(), logger.KeyError, verr.Err)
+		b.reportError(s.sourceError(provider.OpValidate, verr))
+	case err != nil:
+		b.o.logger.Error("merge config failed", logger.KeySource, key, logger.KeyProvider, s.cfg.Provider, logger.KeyError, err)
+		b.reportError(s.sourceError(provider.OpLoad, err))
+	default:
+		b.o.logger.Debug("config merged", logger.KeySource, key, logger.KeyProvider, s.cfg.Provider, logger.KeyRevision, s.pv.Revision())
+	}
+}
+
+// applyMany 以一次更新应用bridge级防抖窗口内发出通知的各source，在事件循环上执行；
+// 只有一个source时同apply，Freeze期间只暂存读取到的配置
+func (b *Bridge) applyMany(msgs map[string]map[string]any) {
+	if len(msgs) == 1 {
+		for key, msg := range msgs {
+			b.apply(key, msg)
+		}
+		return
+	}
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	var (
+		srcs   []*source
+		next   []map[string]any
+		ms     []map[string]any
+		failed []*SourceError
+	)
+	for _, s := range b.ordered() { // 已注销source的滞后通知不在其中
+		msg, ok := msgs[s.key]
+		if !ok {
+			continue
+		}
+		var err error
+		if b.frozen {
+			err = b.hold(s, msg)
+		} else {
+			var setting map[string]any
+			if setting, err = s.pv.Load(); err == nil {
+				srcs = append(srcs, s)
+				next = append(next, deepCopyMap(setting))
+				ms = append(ms, msg)
+			} else {
+				err = s.sourceError(provider.OpLoad, err)
+			}
+		}
+		var serr *SourceError
+		if errors.As(err, &serr) {
+			failed = append(failed, serr)
 		}
 	}
-	b.vp.Store(mergeConfig)
-	if b.hook != nil {
-		b.hook(key, msg)
+	var err error
+	if len(srcs) > 0 {
+		err = b.applyBatch(srcs, next, ms, make([]bool, len(srcs)))
+	}
+	b.mu.Unlock()
+	for _, serr := range failed {
+		b.o.logger.Error("load config failed", logger.KeySource, serr.Key, logger.KeyProvider, serr.Provider, logger.KeyError, serr.Err)
+		b.reportError(serr)
+	}
+	if err != nil {
+		b.o.logger.Error("merge debounced config failed", "sources", len(srcs), logger.KeyError, err)
+		return
+	}
+	b.o.logger.Debug("debounced config merged", "sources", len(srcs))
+}
+
+// commit 在rebuild成功后、仍持有b.mu写锁时调用，把本次视图变更经事件循环交给outbox分发：
+// 先执行hook（可为nil），再把差异分发给订阅方；s为触发变更的source，批量注册、调整优先级时为nil
+func (b *Bridge) commit(s *source, before *Snapshot, hook func(after *Snapshot)) {
+	b.commitAs(EventChanged, s, before, hook)
+}
+
+// commitAs 同commit，以tp类型的事件分发；EventChanged以外的事件即使差异为空也会分发。
+// 视图被Rollback固定时，本次提交同时解除固定，EventChanged改为以EventUnpinned分发
+func (b *Bridge) commitAs(tp EventType, s *source, before *Snapshot, hook func(after *Snapshot)) {
+	after := b.Snapshot()
+	if b.pinned != 0 {
+		b.pinned = 0
+		if tp == EventChanged {
+			tp = EventUnpinned
+		}
+	}
+	ev := newChangeEvent(tp, s, after.gen)
+	if after != before { // 视图未变化时不占用历史
+		b.recordLocked(ev, before, after, nil)
+	}
+	b.enqueue(func() {
+		if hook != nil {
+			b.deliver(func() {
+				hook(after)
+			})
+		}
+		b.publish(ev, before, after)
+	})
+}
+
+// publish 计算before与after的差异、刷新Value后交给outbox分发给订阅方，EventChanged差异为空时不分发
+func (b *Bridge) publish(ev ChangeEvent, before, after *Snapshot) {
+	ev.Added, ev.Removed, ev.Modified = before.Diff(after)
+	if ev.Empty() && ev.Type == EventChanged {
+		return
+	}
+	b.updateValues(ev, after)
+	b.deliver(func() {
+		b.dispatch(ev)
+	})
+}
+
+// publishRejected 以被拒绝的候选配置与当前视图的差异分发EventValidationFailed
+func (b *Bridge) publishRejected(ev ChangeEvent, before *Snapshot, verr *ValidationError) {
+	ev.Added, ev.Removed, ev.Modified = before.Diff(verr.candidate)
+	ev.Err = verr.Err
+	b.deliver(func() {
+		b.dispatch(ev)
+	})
+}
+
+// newChangeEvent 在持有b.mu时记录事件的来源，差异在事件循环上分发前再计算
+func newChangeEvent(tp EventType, s *source, gen uint64) ChangeEvent {
+	ev := ChangeEvent{
+		Type:       tp,
+		Generation: gen,
+		Time:       time.Now(),
+	}
+	if s != nil {
+		ev.Source = s.key
+		ev.Provider = s.pv.Name()
+		ev.Revision = s.pv.Revision()
+	}
+	return ev
+}
+
+// reload 重新读取s的配置，只对s前后涉及的顶层key重新合并，调用方需持有b.mu写锁；
+// 失败时s的缓存与视图均保持不变
+func (b *Bridge) reload(s *source) error {
+	old := s.settings
+	if err := s.load(); err != nil {
+		return err
+	}
+	return b.remergeSource(s, old)
+}
+
+// remergeSource s的缓存已由old替换为新的配置，只对前后涉及的顶层key重新合并，失败时恢复为old
+func (b *Bridge) remergeSource(s *source, old map[string]any) error {
+	if err := b.rebuild(s.key, changedKeys(old, s.settings)); err != nil {
+		s.settings = old
+		return err
+	}
+	return nil
+}
+
+// applySources 把各source的配置替换为next后作为一次更新合并提交，提交后按顺序以msgs触发hook，调用方需持有b.mu写锁；
+// 失败时各source与视图均保持不变，校验失败时分发EventValidationFailed
+func (b *Bridge) applySources(srcs []*source, next, msgs []map[string]any) error {
+	old := make([]map[string]any, len(srcs))
+	keys := make(map[string]struct{})
+	for i, s := range srcs {
+		old[i] = s.settings
+		for k := range changedKeys(s.settings, next[i]) {
+			keys[k] = struct{}{}
+		}
+		s.settings = next[i]
+	}
+	var (
+		trigger *source
+		key     string
+	)
+	if len(srcs) == 1 {
+		trigger, key = srcs[0], srcs[0].key
+	}
+	before := b.Snapshot()
+	if err := b.rebuild(key, keys); err != nil {
+		for i, s := range srcs {
+			s.settings = old[i]
+		}
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			ev := newChangeEvent(EventValidationFailed, trigger, before.gen)
+			b.enqueue(func() {
+				b.publishRejected(ev, before, verr)
+			})
+		}
+		return err
+	}
+	for _, s := range srcs {
+		b.dropStaged(s) // 已被更新的配置取代
+		b.cacheLocked(s)
+	}
+	b.commit(trigger, before, func(*Snapshot) {
+		for i, s := range srcs {
+			b.fireHook(s.key, msgs[i])
+		}
+	})
+	return nil
+}
+
+// changedKeys 返回old与settings涉及的全部顶层key
+func changedKeys(old, settings map[string]any) map[string]struct{} {
+	keys := topKeys(old)
+	for k := range topKeys(settings) {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
+
+// rebuild 按Layers顺序合并各source缓存的配置，通过校验后替换视图，调用方需持有b.mu写锁；
+// keys为本次受影响的顶层key，其余顶层key沿用当前视图，为nil或视图被Rollback固定时全量合并。
+// key为触发本次合并的source，校验失败时返回*ValidationError且视图保持不变
+func (b *Bridge) rebuild(key string, keys map[string]struct{}) error {
+	candidate, err := b.merge(keys)
+	if err != nil {
+		return err
+	}
+	return b.install(key, candidate)
+}
+
+// merge 按Layers顺序合并各source缓存的配置得到候选视图，不校验也不替换视图，调用方需持有b.mu
+func (b *Bridge) merge(keys map[string]struct{}) (*Snapshot, error) {
+	ordered := b.ordered()
+	settings := make([]map[string]any, 0, len(ordered))
+	for _, s := range ordered {
+		settings = append(settings, s.settings)
+	}
+	var (
+		tree map[string]any
+		err  error
+	)
+	if keys == nil || b.pinned != 0 {
+		tree, err = mergeTree(b.o.mergeStrategy, settings...)
+	} else {
+		tree, err = remerge(b.o.mergeStrategy, b.Snapshot().tree, keys, settings...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	candidate := newSnapshot(tree)
+	candidate.gen = b.Snapshot().gen + 1
+	return candidate, nil
+}
+
+// install 校验merge得到的候选视图并替换当前视图，调用方需持有b.mu写锁；
+// 候选视图与当前视图相同时（如变更被高优先级的source覆盖）保留当前视图，generation不变
+func (b *Bridge) install(key string, candidate *Snapshot) error {
+	if b.pinned == 0 && candidate.Equal(b.Snapshot()) {
+		return nil
+	}
+	if err := b.validate(key, candidate); err != nil {
+		return err
+	}
+	b.snap.Store(candidate)
+	return nil
+}
+
+func (b *Bridge) fireHook(key string, msg map[string]any) {
+	b.handlerMu.RLock()
+	hook := b.hook
+	b.handlerMu.RUnlock()
+	if hook == nil {
+		return
+	}
+	switch b.o.hookDispatch {
+	case HookDispatchAsync:
+		go hook(key, msg)
+	default:
+		hook(key, msg)
 	}
 }
 
@@ -89,83 +481,295 @@ func (b *Bridge) Update(key string, msg map[string]any) {
 			"path": string,
 			“timeout”： time.Duration/number/string, // number类型时单位为Millisecond，默认5s
 		}
+	provider = 'offline'时：
+		properties := map[string]interface{}{
+			"content": string/[]byte, // 按ConfigType解码的配置内容，与path二选一
+			"path": string, // 本地配置文件
+			"revision": string, // 可选，默认为内容的MD5
+		}
 */
 
 type Config struct {
-	Provider   provider.CfgProviderType `json:"provider"`    // Provider CfgProviderType, e.g., "nacos".
-	Properties map[string]interface{}   `json:"properties"`  // client and server init param.
-	Sources    []*provider.Source       `json:"sources"`     // nacos支持同一个实例下支持加载多个source，provider='nacos'时必传
-	ConfigType codec.CfgFileType        `json:"config_type"` // 配置文件的格式类型，目前支持"yaml"和"json"
+	Provider        provider.CfgProviderType `json:"provider"`          // Provider CfgProviderType, e.g., "nacos".
+	Properties      map[string]interface{}   `json:"properties"`        // client and server init param.
+	Sources         []*provider.Source       `json:"sources"`           // nacos支持同一个实例下支持加载多个source，provider='nacos'时必传
+	ConfigType      codec.CfgFileType        `json:"config_type"`       // 配置文件的格式类型，目前支持"yaml"和"json"
+	Priority        int                      `json:"priority"`          // 合并优先级，值大的覆盖值小的，相同时后注册的覆盖先注册的
+	Debounce        time.Duration            `json:"debounce"`          // 变更通知的防抖窗口，>0时覆盖WithDebounce，<0时该source不防抖
+	DebounceMaxWait time.Duration            `json:"debounce_max_wait"` // 持续有通知时的最长等待，<=0时为10倍Debounce
+	Optional        bool                     `json:"optional"`          // 可选的source连接失败时在后台重试，不影响注册与Ready
+	Retry           *RetryPolicy             `json:"retry"`             // 为nil时使用WithRetryPolicy的设置
 }
 
-func (b *Bridge) RegisterSource(key string, cfg *Config) error {
-	pv, err := provider.NewProvider(
-		b.ctx,
+// configType 未设置ConfigType时与provider一致，按yaml处理
+func (c *Config) configType() codec.CfgFileType {
+	if c.ConfigType == "" {
+		return codec.CfgFileTypeYaml
+	}
+	return c.ConfigType
+}
+
+func (b *Bridge) newProvider(ctx context.Context, key string, cfg *Config) (provider.Provider, error) {
+	return provider.NewProvider(
+		ctx,
 		cfg.Provider,
 		provider.WithMediator(b.coordinator),
 		provider.WithProperties(cfg.Properties),
 		provider.WithSources(cfg.Sources),
 		provider.WithConfigType(cfg.ConfigType),
 		provider.WithCustomKey(key),
+		provider.WithLogger(b.o.logger),
 	)
+}
+
+// createSource 创建provider，每个source使用独立的ctx，注销时cancel以停止其监听
+func (b *Bridge) createSource(key string, cfg *Config) (*source, error) {
+	b.mu.RLock()
+	closed := b.closed
+	b.mu.RUnlock()
+	if closed {
+		return nil, ErrBridgeClosed
+	}
+	ctx, cancel := context.WithCancel(b.ctx)
+	s := &source{
+		key:      key,
+		cfg:      cfg,
+		priority: cfg.Priority,
+		cancel:   cancel,
+	}
+	s.deb = b.sourceDebouncer(key, cfg)
+	pv, err := b.factory(ctx, key, cfg)
 	if err != nil {
-		return err
+		cancel()
+		if errors.Is(err, provider.ErrUnknownProvider) {
+			return nil, err
+		}
+		return nil, s.sourceError(provider.OpLoad, err)
+	}
+	s.pv = pv
+	if err = s.load(); err != nil {
+		_ = s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// refreshLocked 重新读取s的配置：s创建完成到加入pvm之间的变更通知找不到s而被忽略，
+// 调用方需持有b.mu写锁；读取失败时沿用创建时读取的配置
+func (b *Bridge) refreshLocked(s *source) {
+	if s.stale {
+		return
+	}
+	if err := s.load(); err != nil {
+		b.o.logger.Warn("reload created source failed, using the initial config", logger.KeySource, s.key, logger.KeyProvider, s.cfg.Provider, logger.KeyError, err)
 	}
+}
+
+// addSource 记录新的source，调用方需持有b.mu写锁
+func (b *Bridge) addSource(s *source) {
+	b.seq++
+	s.seq = b.seq
+	b.pvm[s.key] = s
+}
 
-	newConfig := viper.New()
-	setting, err := pv.Load()
+// RegisterSource 创建source并合并到视图。必需的source按重试策略重试，次数用完后返回最后一次的错误；
+// 可选的source（Config.Optional）首次连接失败时转为后台注册并返回nil，见RegisterSourceAsync。
+// 开启WithCacheDir时，远端不可用且有缓存的source改用缓存注册，见EventStale
+func (b *Bridge) RegisterSource(key string, cfg *Config) error {
+	b.mu.RLock()
+	exists := b.exists(key)
+	b.mu.RUnlock()
+	if exists {
+		return fmt.Errorf("%w, use ReplaceSource: %s", ErrSourceExists, key)
+	}
+	attempts := 1
+	if !cfg.Optional {
+		attempts = b.retryPolicy(cfg).MaxAttempts
+	}
+	s, err := b.createWithRetry(context.Background(), key, cfg, attempts)
 	if err != nil {
+		if stale := b.staleSource(key, cfg, err); stale != nil {
+			return b.registerStale(stale, nil)
+		}
+		if cfg.Optional && retryable(err) {
+			b.o.logger.Warn("optional source unavailable, retrying in background", logger.KeySource, key, logger.KeyProvider, cfg.Provider, logger.KeyError, err)
+			return b.RegisterSourceAsync(key, cfg)
+		}
 		return err
 	}
-	if err = newConfig.MergeConfigMap(setting); err != nil {
+	return b.add(s, nil)
+}
+
+// exists key已注册或正在后台注册，调用方需持有b.mu
+func (b *Bridge) exists(key string) bool {
+	_, registered := b.pvm[key]
+	_, pending := b.pending[key]
+	return registered || pending
+}
+
+// add 将已创建的source合并到视图，失败时关闭它；p不为nil时为后台注册，只有p仍是key的待注册项时才会加入
+func (b *Bridge) add(s *source, p *pendingSource) error {
+	return b.addAs(EventChanged, s, p)
+}
+
+// addAs 同add，以tp类型的事件分发
+func (b *Bridge) addAs(tp EventType, s *source, p *pendingSource) error {
+	key := s.key
+	b.mu.Lock()
+	switch {
+	case b.closed:
+		b.mu.Unlock()
+		_ = s.close()
+		return ErrBridgeClosed
+	case p != nil && b.pending[key] != p:
+		b.mu.Unlock()
+		_ = s.close()
+		return errPendingCancelled
+	case p == nil && b.exists(key):
+		b.mu.Unlock()
+		_ = s.close()
+		return fmt.Errorf("%w, use ReplaceSource: %s", ErrSourceExists, key)
+	}
+	before := b.Snapshot()
+	b.refreshLocked(s)
+	b.addSource(s)
+	if err := b.rebuild(key, topKeys(s.settings)); err != nil {
+		delete(b.pvm, key)
+		b.mu.Unlock()
+		_ = s.close()
 		return err
 	}
+	if p != nil {
+		delete(b.pending, key)
+	}
+	b.commitAs(tp, s, before, nil)
+	b.cacheLocked(s)
+	b.mu.Unlock()
+	if p != nil {
+		p.finish(nil)
+	}
+	b.o.logger.Info("source registered", logger.KeySource, key, logger.KeyProvider, s.cfg.Provider, logger.KeyRevision, s.pv.Revision())
+	return nil
+}
 
+// UnregisterSource 停止source的监听并释放其client，重新合并后以消失的key触发hook；
+// 对仍在后台注册的source则停止重试
+func (b *Bridge) UnregisterSource(key string) error {
 	b.mu.Lock()
-	defer b.mu.Unlock()
-	b.pvm[key] = pv
-
-	current := b.vp.Load().(*viper.Viper).AllSettings()
-	if err = newConfig.MergeConfigMap(current); err != nil {
+	if p, ok := b.pending[key]; ok {
+		delete(b.pending, key)
+		b.mu.Unlock()
+		p.finish(errPendingCancelled)
+		return nil
+	}
+	s, ok := b.pvm[key]
+	if !ok {
+		b.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrSourceNotFound, key)
+	}
+	before := b.Snapshot()
+	delete(b.pvm, key)
+	if err := b.rebuild(key, topKeys(s.settings)); err != nil {
+		b.pvm[key] = s
+		b.mu.Unlock()
 		return err
 	}
-	b.vp.Store(newConfig)
+	s.cancel()
+	b.commit(s, before, func(after *Snapshot) {
+		b.fireRemoved(key, before, after)
+	})
+	b.mu.Unlock()
+	_ = s.close()
+	b.o.logger.Info("source unregistered", logger.KeySource, key, logger.KeyProvider, s.cfg.Provider)
 	return nil
 }
 
-func (b *Bridge) RegisterSourceBatch(sources map[string]*Config) error {
-	newConfig := viper.New()
-	for key, cfg := range sources {
-		pv, err := provider.NewProvider(
-			b.ctx,
-			cfg.Provider,
-			provider.WithMediator(b.coordinator),
-			provider.WithProperties(cfg.Properties),
-			provider.WithSources(cfg.Sources),
-			provider.WithConfigType(cfg.ConfigType),
-			provider.WithCustomKey(key),
-		)
-		if err != nil {
-			return err
-		}
-		setting, err := pv.Load()
-		if err != nil {
-			return err
-		}
-		if err = newConfig.MergeConfigMap(setting); err != nil {
-			return err
-		}
-		b.mu.Lock()
-		b.pvm[key] = pv
-		b.mu.Unlock()
+// ReplaceSource 用cfg重新创建source并保留其在Layers中的位置，新provider创建失败时原source不受影响；
+// 先以新source的配置触发hook，若有key因此消失再以消失的key触发一次
+func (b *Bridge) ReplaceSource(key string, cfg *Config) error {
+	b.mu.RLock()
+	_, exists := b.pvm[key]
+	b.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrSourceNotFound, key)
+	}
+	s, err := b.createSource(key, cfg)
+	if err != nil {
+		return err
 	}
 
 	b.mu.Lock()
-	defer b.mu.Unlock()
-	current := b.vp.Load().(*viper.Viper).AllSettings()
-	if err := newConfig.MergeConfigMap(current); err != nil {
+	old, ok := b.pvm[key]
+	if !ok {
+		b.mu.Unlock()
+		_ = s.close()
+		return fmt.Errorf("%w: %s", ErrSourceNotFound, key)
+	}
+	before := b.Snapshot()
+	b.refreshLocked(s)
+	s.seq = old.seq
+	b.pvm[key] = s
+	keys := topKeys(old.settings)
+	for k := range topKeys(s.settings) {
+		keys[k] = struct{}{}
+	}
+	if err = b.rebuild(key, keys); err != nil {
+		b.pvm[key] = old
+		b.mu.Unlock()
+		_ = s.close()
 		return err
 	}
-	b.vp.Store(newConfig)
+	old.cancel()
+	setting := deepCopyMap(s.settings)
+	b.commit(s, before, func(after *Snapshot) {
+		b.fireHook(key, setting)
+		b.fireRemoved(key, before, after)
+	})
+	b.cacheLocked(s)
+	b.mu.Unlock()
+	_ = old.close()
+	b.o.logger.Info("source replaced", logger.KeySource, key, logger.KeyProvider, cfg.Provider, logger.KeyRevision, s.pv.Revision())
 	return nil
 }
+
+// Close 关闭全部provider并等待其goroutine退出，合并视图保留关闭前的内容，之后不能再注册source
+func (b *Bridge) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.done)
+	sources := b.ordered()
+	pending := b.pending
+	b.pending = make(map[string]*pendingSource)
+	b.mu.Unlock()
+	for _, p := range pending {
+		p.finish(ErrBridgeClosed)
+	}
+	if b.deb != nil {
+		b.deb.stop()
+	}
+
+	var errs []error
+	for _, s := range sources {
+		if err := s.close(); err != nil {
+			errs = append(errs, fmt.Errorf("close source %s: %w", s.key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fireRemoved 以before中存在、after中已不存在的key（值为nil）触发hook
+func (b *Bridge) fireRemoved(key string, before, after *Snapshot) {
+	removed := make(map[string]any)
+	for _, k := range before.Keys() {
+		if !after.IsSet(k) {
+			removed[k] = nil
+		}
+	}
+	if len(removed) > 0 {
+		b.fireHook(key, removed)
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"github.com/fufuzion/confremote-pilot/codec"
 	"github.com/fufuzion/confremote-pilot/provider"
 	"github.com/nacos-group/nacos-sdk-go/v2/common/constant"
+	"math/rand"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 )
@@ -188,3 +191,185 @@ func TestBridge_RegisterZookeeperSource(t *testing.T) {
 		}
 	}
 }
+
+func TestBridge_UnregisterSource(t *testing.T) {
+	b, f := newFakeBridge(t)
+	var removed map[string]any
+	b.SetHook(func(key string, msg map[string]any) {
+		removed = msg
+	})
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{"name": "a", "db": map[string]any{"host": "h"}})); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RegisterSource("b", fakeConfig(map[string]any{"name": "b"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RegisterSource("a", fakeConfig(nil)); err == nil {
+		t.Error("duplicate RegisterSource should fail")
+	}
+	old := f.get("a")
+	if err := b.UnregisterSource("a"); err != nil {
+		t.Fatal(err)
+	}
+	b.flush()
+	if got := b.Get("db.host"); got != nil {
+		t.Errorf("db.host = %v, want nil", got)
+	}
+	if _, ok := removed["db.host"]; !ok || len(removed) != 1 {
+		t.Errorf("removed = %v, want db.host", removed)
+	}
+	removed = nil
+	old.push(map[string]any{"name": "stale"})
+	if removed != nil || b.Get("name") != "b" {
+		t.Error("notification from unregistered source was applied")
+	}
+	if err := b.UnregisterSource("a"); err == nil {
+		t.Error("UnregisterSource of unknown key should fail")
+	}
+}
+
+func TestBridge_ReplaceSource(t *testing.T) {
+	b, f := newFakeBridge(t)
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{"name": "a", "port": 1})); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RegisterSource("b", fakeConfig(map[string]any{"name": "b"})); err != nil {
+		t.Fatal(err)
+	}
+	old := f.get("a")
+	if err := b.ReplaceSource("a", fakeConfig(map[string]any{"name": "a2"})); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Get("name"); got != "b" {
+		t.Errorf("name = %v, want b (replace keeps layer position)", got)
+	}
+	if got := b.Get("port"); got != nil {
+		t.Errorf("port = %v, want nil", got)
+	}
+	if f.get("a") == old {
+		t.Error("provider was not recreated")
+	}
+	bad := &Config{Provider: fakeProviderType, Properties: map[string]interface{}{"err": "boom"}}
+	if err := b.ReplaceSource("a", bad); err == nil {
+		t.Error("ReplaceSource with failing provider should fail")
+	}
+	if got := len(b.Layers()); got != 2 {
+		t.Errorf("layers = %d, want 2", got)
+	}
+}
+
+func TestBridge_Close(t *testing.T) {
+	b, f := newFakeBridge(t)
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{"name": "a"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RegisterSource("b", fakeConfig(nil)); err != nil {
+		t.Fatal(err)
+	}
+	replaced := f.get("b")
+	if err := b.ReplaceSource("b", fakeConfig(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if !replaced.isClosed() {
+		t.Error("replaced provider was not closed")
+	}
+	if got := b.Layers()[0].Revision; got != "0" {
+		t.Errorf("revision = %q, want 0", got)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !f.get("a").isClosed() || !f.get("b").isClosed() {
+		t.Error("Close did not close every provider")
+	}
+	if got := b.Get("name"); got != "a" {
+		t.Errorf("name after Close = %v, want a", got)
+	}
+	if err := b.RegisterSource("c", fakeConfig(nil)); err == nil {
+		t.Error("RegisterSource after Close should fail")
+	}
+	if err := b.Close(); err != nil {
+		t.Errorf("second Close = %v", err)
+	}
+}
+
+func TestBridge_IncrementalUpdate(t *testing.T) {
+	for _, strategy := range []MergeStrategy{MergeDeep, MergeReplace} {
+		b, f := newFakeBridge(t, WithMergeStrategy(strategy))
+		r := rand.New(rand.NewSource(2))
+		keys := []string{"s0", "s1", "s2", "s3"}
+		for i, key := range keys {
+			cfg := fakeConfig(randomSetting(r, 0))
+			cfg.Priority = i % 2
+			if err := b.RegisterSource(key, cfg); err != nil {
+				t.Fatal(err)
+			}
+		}
+		loads := f.get("s0").loadCount()
+		for i := 0; i < 100; i++ {
+			f.get(keys[1+r.Intn(len(keys)-1)]).push(randomSetting(r, 0))
+			settings := make([]map[string]any, 0, len(keys))
+			for _, l := range b.Layers() {
+				data, _ := f.get(l.Key).Load()
+				settings = append(settings, data)
+			}
+			want, err := mergeTree(strategy, settings...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := b.Snapshot().Tree(); !reflect.DeepEqual(got, want) {
+				t.Fatalf("strategy %d update %d: tree = %v, full rebuild = %v", strategy, i, got, want)
+			}
+		}
+		// s0未发生变化，除上面比对时的读取外不应被重新Load
+		if got := f.get("s0").loadCount() - loads; got != 100 {
+			t.Errorf("strategy %d: s0 loaded %d times, want 100", strategy, got)
+		}
+	}
+}
+
+func TestBridge_Generation(t *testing.T) {
+	b, f := newFakeBridge(t)
+	var gens []uint64
+	b.OnChange(func(ev ChangeEvent) {
+		gens = append(gens, ev.Generation) // 事件循环上依次回调，无需加锁
+	})
+	keys := []string{"a", "b", "c"}
+	for _, key := range keys {
+		if err := b.RegisterSource(key, fakeConfig(map[string]any{key: 0})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		wg.Add(1)
+		go func(p *fakeProvider) {
+			defer wg.Done()
+			for i := 1; i <= 50; i++ {
+				p.push(map[string]any{p.key: i})
+			}
+		}(f.get(key))
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			_ = b.SetPriority("a", i%3)
+		}
+	}()
+	wg.Wait()
+	b.flush()
+	for i := 1; i < len(gens); i++ {
+		if gens[i] <= gens[i-1] {
+			t.Fatalf("generation not strictly increasing at %d: %v", i, gens[i-1:i+1])
+		}
+	}
+	if len(gens) == 0 || gens[len(gens)-1] != b.Generation() {
+		t.Errorf("last event generation = %v, Generation() = %d", gens, b.Generation())
+	}
+	for _, key := range keys {
+		if got := b.Get(key); got != 50 {
+			t.Errorf("%s = %v, want 50", key, got)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package confremote_pilot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/fufuzion/confremote-pilot/codec"
+	"github.com/fufuzion/confremote-pilot/provider"
+	"io"
+	"reflect"
+	"time"
+)
+
+// bundleVersion 快照包格式的版本，格式不兼容时递增
+const bundleVersion = 1
+
+// bundle ExportSnapshot写出的快照包，Sources按合并顺序排列
+type bundle struct {
+	Version    int            `json:"version"`
+	Generation uint64         `json:"generation"`
+	CreatedAt  time.Time      `json:"created_at"`
+	Sources    []bundleSource `json:"sources"`
+}
+
+// bundleSource 一个source的内容与Config元数据；Properties可能包含账号密钥，不写入快照包
+type bundleSource struct {
+	Key        string                   `json:"key"`
+	Provider   provider.CfgProviderType `json:"provider"`
+	Sources    []*provider.Source       `json:"sources,omitempty"`
+	ConfigType codec.CfgFileType        `json:"config_type"`
+	Priority   int                      `json:"priority"`
+	Revision   string                   `json:"revision"`
+	Content    string                   `json:"content"`         // 合并后的配置按ConfigType重新编码，不保留注释与格式，多个dataId合并为一份
+	Parts      []bundlePart             `json:"parts,omitempty"` // provider实现了PartLoader时的原始内容，nacos为各dataId，按合并顺序排列
+}
+
+// bundlePart 一个dataId或zookeeper节点的原始内容与版本
+type bundlePart struct {
+	Source   *provider.Source `json:"source,omitempty"`
+	Path     string           `json:"path,omitempty"`
+	Revision string           `json:"revision"`
+	Content  string           `json:"content"`
+}
+
+// ExportSnapshot 将全部已注册source的内容、版本与Config元数据写为一个快照包，可由NewFromSnapshot离线加载。
+// nacos与zookeeper实现了provider.PartLoader，逐个dataId或节点写入远端的原始内容与版本；etcd/consul/firestore经由viper读取，
+// 拿不到原始内容，写入的是重新编码的合并结果，不保留注释与格式。
+// Freeze或暂存变更使source的配置落后于远端时，原始内容与已合并的配置不一致，此时同样只写入合并结果
+func (b *Bridge) ExportSnapshot(w io.Writer) error {
+	b.mu.RLock()
+	ordered := b.ordered()
+	bd := bundle{
+		Version:    bundleVersion,
+		Generation: b.Generation(),
+		CreatedAt:  time.Now(),
+		Sources:    make([]bundleSource, 0, len(ordered)),
+	}
+	settings := make([]map[string]any, 0, len(ordered))
+	for _, s := range ordered {
+		bd.Sources = append(bd.Sources, bundleSource{
+			Key:        s.key,
+			Provider:   s.cfg.Provider,
+			Sources:    s.cfg.Sources,
+			ConfigType: s.cfg.configType(),
+			Priority:   s.priority,
+			Revision:   s.pv.Revision(),
+			Parts:      rawParts(s),
+		})
+		settings = append(settings, s.settings)
+	}
+	b.mu.RUnlock()
+
+	for i := range bd.Sources {
+		content, err := codec.NewCodec(bd.Sources[i].ConfigType).Encode(settings[i])
+		if err != nil {
+			return fmt.Errorf("encode source %s: %w", bd.Sources[i].Key, err)
+		}
+		bd.Sources[i].Content = string(content)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(bd)
+}
+
+// rawParts 各dataId的原始内容，仅在合并后与source当前的配置一致时返回，调用方需持有b.mu
+func rawParts(s *source) []bundlePart {
+	pl, ok := s.pv.(provider.PartLoader)
+	if !ok {
+		return nil
+	}
+	parts := pl.LoadParts()
+	if len(parts) == 0 || !reflect.DeepEqual(provider.MergeParts(parts), s.settings) {
+		return nil
+	}
+	ret := make([]bundlePart, 0, len(parts))
+	for _, part := range parts {
+		ret = append(ret, bundlePart{Source: part.Source, Path: part.Path, Revision: part.Revision, Content: part.Content})
+	}
+	return ret
+}
+
+// NewFromSnapshot 创建一个完全离线的Bridge：快照包中的每个source以offline provider按原顺序注册，不连接任何远端；
+// 包含各dataId原始内容的source按dataId分别加载，Explain仍能给出提供某个key的dataId
+func NewFromSnapshot(ctx context.Context, r io.Reader, opts ...BridgeOption) (*Bridge, error) {
+	var bd bundle
+	if err := json.NewDecoder(r).Decode(&bd); err != nil {
+		return nil, fmt.Errorf("decode snapshot: %w", err)
+	}
+	if bd.Version != bundleVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d, want %d", bd.Version, bundleVersion)
+	}
+	b := New(ctx, opts...)
+	for _, src := range bd.Sources {
+		cfg := &Config{
+			Provider: provider.CfgProviderOffline,
+			Properties: map[string]interface{}{
+				"content":  src.Content,
+				"revision": src.Revision,
+			},
+			Sources:    src.Sources,
+			ConfigType: src.ConfigType,
+			Priority:   src.Priority,
+		}
+		if len(src.Parts) > 0 {
+			parts := make([]provider.Part, 0, len(src.Parts))
+			for _, part := range src.Parts {
+				parts = append(parts, provider.Part{Source: part.Source, Path: part.Path, Revision: part.Revision, Content: part.Content})
+			}
+			cfg.Properties = map[string]interface{}{
+				"parts":    parts,
+				"revision": src.Revision,
+			}
+		}
+		// 逐个注册以保留相同priority下的合并顺序
+		if err := b.RegisterSource(src.Key, cfg); err != nil {
+			_ = b.Close()
+			return nil, fmt.Errorf("load source %s from snapshot: %w", src.Key, err)
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,150 @@
+package confremote_pilot
+
+import (
+	"bytes"
+	"context"
+	"github.com/fufuzion/confremote-pilot/logger"
+	"github.com/fufuzion/confremote-pilot/provider"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBridge_ExportSnapshot(t *testing.T) {
+	b, f := newFakeBridge(t)
+	if err := b.RegisterSource("base", fakeConfig(map[string]any{"a": 1, "m": map[string]any{"x": "base", "y": true}})); err != nil {
+		t.Fatal(err)
+	}
+	high := fakeConfig(map[string]any{"a": 3})
+	high.Priority = 10
+	if err := b.RegisterSource("high", high); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RegisterSource("mid", fakeConfig(map[string]any{"a": 2, "m": map[string]any{"x": "mid"}})); err != nil {
+		t.Fatal(err)
+	}
+	f.get("mid").push(map[string]any{"a": 2, "m": map[string]any{"x": "mid"}, "s": []any{"p", "q"}})
+
+	var buf bytes.Buffer
+	if err := b.ExportSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "data") {
+		t.Errorf("snapshot must not contain properties: %s", buf.String())
+	}
+	nb, err := NewFromSnapshot(context.Background(), &buf, WithLogger(logger.Nop()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nb.Close()
+	if got, want := nb.Snapshot().Tree(), b.Snapshot().Tree(); !reflect.DeepEqual(got, want) {
+		t.Errorf("tree = %v, want %v", got, want)
+	}
+	got, want := nb.Layers(), b.Layers()
+	if len(got) != len(want) {
+		t.Fatalf("layers = %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Key != want[i].Key || got[i].Priority != want[i].Priority || got[i].Revision != want[i].Revision {
+			t.Errorf("layer %d = %+v, want %+v", i, got[i], want[i])
+		}
+		if got[i].Provider != "offline" {
+			t.Errorf("layer %d provider = %s, want offline", i, got[i].Provider)
+		}
+	}
+}
+
+func TestNewFromSnapshot_Version(t *testing.T) {
+	_, err := NewFromSnapshot(context.Background(), strings.NewReader(`{"version":99,"sources":[]}`), WithLogger(logger.Nop()))
+	if err == nil || !strings.Contains(err.Error(), "unsupported snapshot version") {
+		t.Errorf("err = %v, want unsupported version", err)
+	}
+	if _, err = NewFromSnapshot(context.Background(), strings.NewReader(`not json`)); err == nil {
+		t.Error("want decode error")
+	}
+}
+
+func TestBridge_ExportSnapshotParts(t *testing.T) {
+	b, f := newFakeBridge(t)
+	first := provider.Part{
+		Source:   &provider.Source{DataId: "a.yaml", Group: "DEFAULT_GROUP"},
+		Revision: "r1",
+		Content:  "# base\ndb:\n  host: a\n",
+		Settings: map[string]any{"db": map[string]any{"host": "a"}},
+	}
+	second := provider.Part{
+		Source:   &provider.Source{DataId: "b.yaml", Group: "DEFAULT_GROUP"},
+		Revision: "r2",
+		Content:  "port: 1\n",
+		Settings: map[string]any{"port": 1},
+	}
+	if err := b.RegisterSource("app", fakePartsConfig(first, second)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := b.ExportSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `# base`) || !strings.Contains(buf.String(), `"r2"`) {
+		t.Errorf("snapshot must keep the raw content and revision per dataId: %s", buf.String())
+	}
+	nb, err := NewFromSnapshot(context.Background(), &buf, WithLogger(logger.Nop()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nb.Close()
+	if got, want := nb.Snapshot().Tree(), b.Snapshot().Tree(); !reflect.DeepEqual(got, want) {
+		t.Errorf("tree = %v, want %v", got, want)
+	}
+	ex, err := nb.Explain("port")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ex.Winner.Sources) != 1 || ex.Winner.Sources[0].DataId != "b.yaml" || ex.Winner.Revision != "r2" {
+		t.Errorf("winner = %+v, want b.yaml at r2", ex.Winner)
+	}
+
+	// 冻结期间远端已变化，原始内容与已合并的配置不一致，只写入合并结果
+	b.Freeze("test")
+	second.Content, second.Settings = "port: 2\n", map[string]any{"port": 2}
+	f.get("app").pushParts(first, second)
+	buf.Reset()
+	if err = b.ExportSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), `"parts"`) {
+		t.Errorf("snapshot of a frozen source must not contain parts: %s", buf.String())
+	}
+}
+
+func TestBridge_ExportSnapshotNode(t *testing.T) {
+	b, _ := newFakeBridge(t)
+	node := provider.Part{
+		Path:     "/conf/app",
+		Revision: "7",
+		Content:  "# node\nport: 1\n",
+		Settings: map[string]any{"port": 1},
+	}
+	if err := b.RegisterSource("zk", fakePartsConfig(node)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := b.ExportSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `# node`) || !strings.Contains(buf.String(), `"/conf/app"`) {
+		t.Errorf("snapshot must keep the raw node content: %s", buf.String())
+	}
+	nb, err := NewFromSnapshot(context.Background(), &buf, WithLogger(logger.Nop()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nb.Close()
+	ex, err := nb.Explain("port")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ex.Value != 1 || ex.Winner.Revision != "7" || ex.Winner.Path != "/conf/app" || len(ex.Winner.Sources) != 0 {
+		t.Errorf("winner = %+v, want the node at version 7", ex.Winner)
+	}
+}
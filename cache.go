@@ -0,0 +1,256 @@
+package confremote_pilot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fufuzion/confremote-pilot/codec"
+	"github.com/fufuzion/confremote-pilot/logger"
+	"github.com/fufuzion/confremote-pilot/provider"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// WithCacheDir 开启本地缓存：每个source最近一次成功合并的配置写入dir，
+// 启动时远端不可用则以缓存的配置注册该source（EventStale），并在后台重连直到远端恢复（EventRecovered）
+func WithCacheDir(dir string) BridgeOption {
+	return func(o *bridgeOption) {
+		o.cacheDir = dir
+	}
+}
+
+// cacheEntry 缓存文件的内容，配置按source的ConfigType编码，读取时以同一codec解码，数值等类型与远端读取的一致
+type cacheEntry struct {
+	Key        string                   `json:"key"`
+	Provider   provider.CfgProviderType `json:"provider"`
+	ConfigType codec.CfgFileType        `json:"config_type"`
+	Revision   string                   `json:"revision"`
+	Time       time.Time                `json:"time"`
+	Content    string                   `json:"content"`
+}
+
+func (e *cacheEntry) decode() (map[string]any, error) {
+	settings := make(map[string]any)
+	if err := codec.NewCodec(e.ConfigType).Decode([]byte(e.Content), &settings); err != nil {
+		return nil, fmt.Errorf("decode cache %s: %w", e.Key, err)
+	}
+	return settings, nil
+}
+
+func (b *Bridge) cachePath(key string) string {
+	return filepath.Join(b.o.cacheDir, url.PathEscape(key)+".json")
+}
+
+// cacheLocked 把s当前的配置交给事件循环写入缓存，调用方需持有b.mu写锁，使写入顺序与视图更新一致
+func (b *Bridge) cacheLocked(s *source) {
+	if b.o.cacheDir == "" || s.stale {
+		return
+	}
+	entry := &cacheEntry{
+		Key:        s.key,
+		Provider:   s.cfg.Provider,
+		ConfigType: s.cfg.configType(),
+		Revision:   s.pv.Revision(),
+		Time:       time.Now(),
+	}
+	settings := s.settings
+	b.enqueue(func() {
+		content, err := codec.NewCodec(entry.ConfigType).Encode(settings)
+		if err == nil {
+			entry.Content = string(content)
+			err = b.writeCache(entry)
+		}
+		if err != nil {
+			b.o.logger.Warn("write cache failed", logger.KeySource, entry.Key, logger.KeyPath, b.o.cacheDir, logger.KeyError, err)
+		}
+	})
+}
+
+// writeCache 先写临时文件再rename，读取方不会看到写了一半的文件
+func (b *Bridge) writeCache(entry *cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(b.o.cacheDir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(b.o.cacheDir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // rename成功后为空操作
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), b.cachePath(entry.Key))
+}
+
+func (b *Bridge) readCache(key string) (*cacheEntry, error) {
+	data, err := os.ReadFile(b.cachePath(key))
+	if err != nil {
+		return nil, err
+	}
+	entry := &cacheEntry{}
+	if err = json.Unmarshal(data, entry); err != nil {
+		return nil, fmt.Errorf("decode cache %s: %w", key, err)
+	}
+	return entry, nil
+}
+
+// staleSource 远端创建失败时以缓存的配置构造source，未开启缓存或没有可用的缓存时返回nil
+func (b *Bridge) staleSource(key string, cfg *Config, cause error) *source {
+	if b.o.cacheDir == "" || !retryable(cause) {
+		return nil
+	}
+	entry, err := b.readCache(key)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			b.o.logger.Warn("read cache failed", logger.KeySource, key, logger.KeyError, err)
+		}
+		return nil
+	}
+	if entry.Provider != cfg.Provider || entry.ConfigType != cfg.configType() {
+		return nil
+	}
+	settings, err := entry.decode()
+	if err != nil {
+		b.o.logger.Warn("read cache failed", logger.KeySource, key, logger.KeyError, err)
+		return nil
+	}
+	pv := &cachedProvider{entry: entry, settings: settings, cause: cause, done: make(chan struct{})}
+	return &source{
+		key:      key,
+		cfg:      cfg,
+		pv:       pv,
+		priority: cfg.Priority,
+		cancel:   func() {},
+		settings: deepCopyMap(settings),
+		stale:    true,
+	}
+}
+
+// registerStale 以缓存的配置注册source，之后在后台重连远端；p不为nil时为后台注册
+func (b *Bridge) registerStale(s *source, p *pendingSource) error {
+	if err := b.addAs(EventStale, s, p); err != nil {
+		return err
+	}
+	b.startRecover(s)
+	return nil
+}
+
+// startRecover 记录以缓存注册的s并在后台重连远端
+func (b *Bridge) startRecover(s *source) {
+	pv := s.pv.(*cachedProvider)
+	b.o.logger.Warn("source registered from cache", logger.KeySource, s.key, logger.KeyProvider, s.cfg.Provider, logger.KeyRevision, pv.entry.Revision, "cached_at", pv.entry.Time, logger.KeyError, pv.cause)
+	go b.recoverLoop(s)
+}
+
+// recoverLoop 按重试策略重连远端，成功后替换以缓存注册的stale，stale被注销或bridge关闭时退出
+func (b *Bridge) recoverLoop(stale *source) {
+	done := stale.pv.(*cachedProvider).done
+	policy := b.retryPolicy(stale.cfg)
+	for attempt := 1; ; attempt++ {
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-done:
+			timer.Stop()
+			return
+		case <-b.done:
+			timer.Stop()
+			return
+		case <-b.ctx.Done():
+			timer.Stop()
+			return
+		}
+		s, err := b.createSource(stale.key, stale.cfg)
+		if err == nil {
+			err = b.recover(stale, s)
+		}
+		switch {
+		case err == nil, errors.Is(err, errPendingCancelled), errors.Is(err, ErrBridgeClosed):
+			return
+		case !retryable(err):
+			b.o.logger.Error("recover source abandoned, keep using cache", logger.KeySource, stale.key, logger.KeyError, err)
+			return
+		}
+		b.o.logger.Debug("recover source failed", logger.KeySource, stale.key, "attempt", attempt, logger.KeyError, err)
+	}
+}
+
+// recover 用远端创建的s替换stale并保留其在Layers中的位置
+func (b *Bridge) recover(stale, s *source) error {
+	b.mu.Lock()
+	if b.closed || b.pvm[stale.key] != stale {
+		b.mu.Unlock()
+		_ = s.close()
+		return errPendingCancelled
+	}
+	before := b.Snapshot()
+	b.refreshLocked(s)
+	s.seq = stale.seq
+	s.priority = stale.priority
+	b.pvm[s.key] = s
+	keys := topKeys(stale.settings)
+	for k := range topKeys(s.settings) {
+		keys[k] = struct{}{}
+	}
+	if err := b.rebuild(s.key, keys); err != nil {
+		b.pvm[s.key] = stale
+		b.mu.Unlock()
+		_ = s.close()
+		return err
+	}
+	setting := deepCopyMap(s.settings)
+	b.commitAs(EventRecovered, s, before, func(after *Snapshot) {
+		b.fireHook(s.key, setting)
+		b.fireRemoved(s.key, before, after)
+	})
+	b.cacheLocked(s)
+	b.mu.Unlock()
+	_ = stale.close()
+	b.o.logger.Info("source recovered", logger.KeySource, s.key, logger.KeyProvider, s.cfg.Provider, logger.KeyRevision, s.pv.Revision())
+	return nil
+}
+
+// cachedProvider 以缓存的配置代替不可用的远端，不会产生变更通知
+type cachedProvider struct {
+	entry    *cacheEntry
+	settings map[string]any
+	cause    error // 远端不可用的原因
+	done     chan struct{}
+	once     sync.Once
+}
+
+func (p *cachedProvider) Name() string {
+	return p.entry.Provider.ToString()
+}
+
+func (p *cachedProvider) Load() (map[string]any, error) {
+	return deepCopyMap(p.settings), nil
+}
+
+func (p *cachedProvider) Close() error {
+	p.once.Do(func() {
+		close(p.done)
+	})
+	return nil
+}
+
+func (p *cachedProvider) Health() provider.HealthStatus {
+	return provider.HealthStatus{Connected: false, LastRead: p.entry.Time, LastError: p.cause}
+}
+
+func (p *cachedProvider) Revision() string {
+	return p.entry.Revision
+}
@@ -0,0 +1,156 @@
+package confremote_pilot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBridge_CacheFallback(t *testing.T) {
+	dir := t.TempDir()
+	b, f := newFakeBridge(t, WithCacheDir(dir))
+	if err := b.RegisterSource("a/b", fakeConfig(map[string]any{"name": "v1"})); err != nil {
+		t.Fatal(err)
+	}
+	f.get("a/b").push(map[string]any{"name": "v2", "port": 80})
+	entry, err := b.readCache("a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings, _ := entry.decode(); settings["name"] != "v2" || entry.Revision != "1" {
+		t.Errorf("cache = %+v, want the latest content", entry)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 {
+		t.Errorf("cache dir = %v, want a single file and no temp files", files)
+	}
+
+	// 远端不可用时以缓存启动，恢复后替换为远端的配置
+	b2, f2 := newFakeBridge(t, WithCacheDir(dir), WithRetryPolicy(fastRetry))
+	flakyFactory(b2, "a/b", 3)
+	var (
+		mu     sync.Mutex
+		events []ChangeEvent
+	)
+	b2.OnChange(func(ev ChangeEvent) {
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+	})
+	if err = b2.RegisterSource("a/b", fakeConfig(map[string]any{"name": "v3"})); err != nil {
+		t.Fatalf("register with cache fallback: %v", err)
+	}
+	if got := b2.Get("name"); got != "v2" {
+		t.Errorf("name = %v, want cached v2", got)
+	}
+	if got := b2.Get("port"); got != 80 {
+		t.Errorf("port = %v (%T), want the cached value decoded as int", got, got)
+	}
+	if l := b2.Layers(); len(l) != 1 || !l[0].Stale || l[0].Revision != "1" {
+		t.Errorf("layers = %+v, want a stale layer at the cached revision", l)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for b2.Layers()[0].Stale {
+		if time.Now().After(deadline) {
+			t.Fatal("source did not recover")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	b2.flush()
+	if got := b2.Get("name"); got != "v3" {
+		t.Errorf("name = %v, want v3 after recovery", got)
+	}
+	mu.Lock()
+	if len(events) != 2 || events[0].Type != EventStale || events[1].Type != EventRecovered {
+		t.Errorf("events = %+v, want stale then recovered", events)
+	}
+	mu.Unlock()
+	f2.get("a/b").push(map[string]any{"name": "v4"})
+	entry, _ = b2.readCache("a/b")
+	if settings, _ := entry.decode(); settings["name"] != "v4" {
+		t.Errorf("cache not updated after recovery: %+v", entry)
+	}
+}
+
+func TestBridge_CacheMiss(t *testing.T) {
+	dir := t.TempDir()
+	b, _ := newFakeBridge(t, WithCacheDir(dir))
+	flakyFactory(b, "a", 1)
+	if err := b.RegisterSource("a", fakeConfig(nil)); err == nil {
+		t.Error("registration without a cache entry must fail")
+	}
+	if err := os.WriteFile(b.cachePath("b"), []byte("{broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	flakyFactory(b, "b", 1)
+	if err := b.RegisterSource("b", fakeConfig(nil)); err == nil {
+		t.Error("registration with a corrupt cache entry must fail")
+	}
+}
+
+func TestBridge_CacheFallbackBatch(t *testing.T) {
+	dir := t.TempDir()
+	b, _ := newFakeBridge(t, WithCacheDir(dir))
+	if err := b.RegisterSourceBatch(map[string]*Config{
+		"a": fakeConfig(map[string]any{"n": 1}),
+		"c": fakeConfig(map[string]any{"k": 1}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	b.flush()
+
+	b2, _ := newFakeBridge(t, WithCacheDir(dir), WithRetryPolicy(fastRetry))
+	flakyFactory(b2, "a", 3)
+	if err := b2.RegisterSourceBatch(map[string]*Config{
+		"a": fakeConfig(map[string]any{"n": 2}),
+		"b": fakeConfig(map[string]any{"m": 1}),
+	}); err != nil {
+		t.Fatalf("batch with cache fallback: %v", err)
+	}
+	if b2.Get("n") != 1 || b2.Get("m") != 1 {
+		t.Errorf("tree = %v, want cached a and live b", b2.All())
+	}
+	waitRecovered(t, b2, "a")
+	if got := b2.Get("n"); got != 2 {
+		t.Errorf("n = %v, want 2 after recovery", got)
+	}
+
+	// 后台注册同样以缓存就绪
+	b3, _ := newFakeBridge(t, WithCacheDir(dir), WithRetryPolicy(fastRetry))
+	flakyFactory(b3, "c", 3)
+	if err := b3.RegisterSourceAsync("c", fakeConfig(map[string]any{"k": 2})); err != nil {
+		t.Fatal(err)
+	}
+	if err := b3.WaitReady(context.Background(), "c"); err != nil {
+		t.Fatal(err)
+	}
+	if got := b3.Get("k"); got != 1 && got != 2 {
+		t.Errorf("k = %v, want the cached or recovered value", got)
+	}
+	waitRecovered(t, b3, "c")
+}
+
+// waitRecovered 等待以缓存注册的key恢复为远端的配置
+func waitRecovered(t *testing.T, b *Bridge, key string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stale := false
+		for _, l := range b.Layers() {
+			if l.Key == key && l.Stale {
+				stale = true
+			}
+		}
+		if !stale {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("source %s did not recover", key)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	b.flush()
+}
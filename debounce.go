@@ -0,0 +1,117 @@
+package confremote_pilot
+
+import (
+	"sync"
+	"time"
+)
+
+// WithDebounce 为bridge设置防抖：任一source收到变更通知后等待window，期间各source的后续通知合并为一次更新与一个变更事件；
+// 持续有通知时最迟在第一次通知后maxWait生效，maxWait<=0时为10倍window。window<=0时不防抖；
+// Config.Debounce>0的source使用自己的窗口单独生效，<0的source不防抖
+func WithDebounce(window, maxWait time.Duration) BridgeOption {
+	return func(o *bridgeOption) {
+		o.debounce = window
+		o.debounceMaxWait = maxWait
+	}
+}
+
+// debouncer 合并短时间内的多次变更通知，到期后以各source合并后的msg调用fire；
+// bridge级的debouncer由全部未单独设置窗口的source共享
+type debouncer struct {
+	window  time.Duration
+	maxWait time.Duration
+	fire    func(msgs map[string]map[string]any)
+	shared  bool // bridge级的debouncer，不随单个source关闭
+
+	mu      sync.Mutex
+	pending bool
+	first   time.Time
+	msgs    map[string]map[string]any // source key -> 按到达顺序浅合并的通知内容
+	timer   *time.Timer
+	seq     uint64 // 只有最后一次创建的timer生效
+}
+
+func newDebouncer(window, maxWait time.Duration, fire func(msgs map[string]map[string]any)) *debouncer {
+	if window <= 0 {
+		return nil
+	}
+	if maxWait <= 0 {
+		maxWait = 10 * window
+	}
+	return &debouncer{window: window, maxWait: maxWait, fire: fire}
+}
+
+// sourceDebouncer 返回source使用的debouncer，不需要防抖时返回nil；
+// cfg.Debounce>0时为source单独创建，为0时使用bridge级的debouncer，<0时该source不防抖
+func (b *Bridge) sourceDebouncer(key string, cfg *Config) *debouncer {
+	switch {
+	case cfg.Debounce < 0:
+		return nil
+	case cfg.Debounce > 0:
+		return newDebouncer(cfg.Debounce, cfg.DebounceMaxWait, func(msgs map[string]map[string]any) {
+			b.enqueue(func() {
+				b.apply(key, msgs[key])
+			})
+		})
+	}
+	return b.deb
+}
+
+// notify 记录key的一次通知，msg按到达顺序浅合并，供SetHook设置的hook使用
+func (d *debouncer) notify(key string, msg map[string]any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	if !d.pending {
+		d.pending = true
+		d.first = now
+		d.msgs = make(map[string]map[string]any)
+	}
+	merged, ok := d.msgs[key]
+	if !ok {
+		merged = make(map[string]any, len(msg))
+		d.msgs[key] = merged
+	}
+	for k, v := range msg {
+		merged[k] = v
+	}
+	wait := d.window
+	if rest := d.first.Add(d.maxWait).Sub(now); rest < wait {
+		wait = max(rest, 0)
+	}
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.seq++
+	seq := d.seq
+	d.timer = time.AfterFunc(wait, func() {
+		d.expire(seq)
+	})
+}
+
+func (d *debouncer) expire(seq uint64) {
+	d.mu.Lock()
+	if seq != d.seq || !d.pending {
+		d.mu.Unlock()
+		return
+	}
+	msgs := d.msgs
+	d.pending = false
+	d.msgs = nil
+	d.timer = nil
+	d.mu.Unlock()
+	d.fire(msgs)
+}
+
+// stop 丢弃尚未生效的通知
+func (d *debouncer) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.pending = false
+	d.msgs = nil
+	d.seq++
+}
@@ -0,0 +1,137 @@
+package confremote_pilot
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitGeneration 等待视图的generation达到gen并分发完毕
+func waitGeneration(t *testing.T, b *Bridge, gen uint64) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for b.Generation() < gen {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for generation %d, at %d", gen, b.Generation())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	b.flush()
+}
+
+func TestBridge_Debounce(t *testing.T) {
+	b, f := newFakeBridge(t, WithDebounce(50*time.Millisecond, time.Second))
+	var (
+		mu     sync.Mutex
+		events []ChangeEvent
+		msgs   []map[string]any
+	)
+	b.OnChange(func(ev ChangeEvent) {
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+	})
+	b.SetHook(func(key string, msg map[string]any) {
+		mu.Lock()
+		msgs = append(msgs, msg)
+		mu.Unlock()
+	})
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{"n": 0})); err != nil {
+		t.Fatal(err)
+	}
+	b.flush()
+	gen := b.Generation()
+	for i := 1; i <= 5; i++ {
+		f.get("a").push(map[string]any{"n": i, "k" + string(rune('0'+i)): i})
+	}
+	if got := b.Get("n"); got != 0 {
+		t.Errorf("n = %v before the window elapsed, want 0", got)
+	}
+	waitGeneration(t, b, gen+1)
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if b.Generation() != gen+1 || len(events) != 2 {
+		t.Fatalf("generation = %d, events = %d, want one coalesced update", b.Generation()-gen, len(events))
+	}
+	if got := b.Get("n"); got != 5 {
+		t.Errorf("n = %v, want 5", got)
+	}
+	if ev := events[1]; len(ev.Modified) != 1 || len(ev.Added) != 1 {
+		t.Errorf("coalesced event = %+v", ev)
+	}
+	if len(msgs) != 1 || len(msgs[0]) != 6 {
+		t.Errorf("hook msgs = %v, want a single merged msg", msgs)
+	}
+}
+
+func TestBridge_DebounceMaxWait(t *testing.T) {
+	b, f := newFakeBridge(t)
+	cfg := fakeConfig(map[string]any{"n": 0})
+	cfg.Debounce = 50 * time.Millisecond
+	cfg.DebounceMaxWait = 100 * time.Millisecond
+	if err := b.RegisterSource("a", cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RegisterSource("b", fakeConfig(map[string]any{"m": 0})); err != nil {
+		t.Fatal(err)
+	}
+	b.flush()
+	gen := b.Generation()
+	f.get("b").push(map[string]any{"m": 1})
+	if b.Generation() != gen+1 {
+		t.Error("source without debounce must apply immediately")
+	}
+	// 每20ms一次通知，始终不会空闲满一个窗口，依靠maxWait生效
+	for i := 1; i <= 15; i++ {
+		f.get("a").push(map[string]any{"n": i})
+		time.Sleep(20 * time.Millisecond)
+	}
+	if got := b.Generation() - gen - 1; got < 2 || got > 4 {
+		t.Errorf("updates during continuous changes = %d, want bounded by maxWait", got)
+	}
+	waitGeneration(t, b, b.Generation())
+	time.Sleep(100 * time.Millisecond)
+	if got := b.Get("n"); got != 15 {
+		t.Errorf("n = %v, want 15", got)
+	}
+}
+
+func TestBridge_DebounceAcrossSources(t *testing.T) {
+	b, f := newFakeBridge(t, WithDebounce(50*time.Millisecond, time.Second))
+	var (
+		mu     sync.Mutex
+		events []ChangeEvent
+		hooked []string
+	)
+	b.SetHook(func(key string, msg map[string]any) {
+		mu.Lock()
+		hooked = append(hooked, key)
+		mu.Unlock()
+	})
+	for _, key := range []string{"a", "b"} {
+		if err := b.RegisterSource(key, fakeConfig(map[string]any{key: 0})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.flush()
+	b.OnChange(func(ev ChangeEvent) {
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+	})
+	gen := b.Generation()
+	f.get("a").push(map[string]any{"a": 1})
+	f.get("b").push(map[string]any{"b": 1})
+	waitGeneration(t, b, gen+1)
+	time.Sleep(100 * time.Millisecond)
+	b.flush()
+	mu.Lock()
+	defer mu.Unlock()
+	if b.Generation() != gen+1 || len(events) != 1 || len(events[0].Modified) != 2 {
+		t.Fatalf("generation = +%d, events = %+v, want one merged update", b.Generation()-gen, events)
+	}
+	if len(hooked) != 2 {
+		t.Errorf("hooked = %v, want one call per source", hooked)
+	}
+}
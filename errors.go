@@ -0,0 +1,58 @@
+package confremote_pilot
+
+import (
+	"errors"
+	"github.com/fufuzion/confremote-pilot/provider"
+)
+
+var (
+	ErrUnknownProvider = provider.ErrUnknownProvider
+	ErrSourceExists    = errors.New("source already registered")
+	ErrSourceNotFound  = errors.New("source not found")
+	ErrBridgeClosed    = errors.New("bridge closed")
+)
+
+// SourceError 某个source在load、decode、watch、reconnect等操作上的失败，Op取值见provider.Op*
+type SourceError = provider.SourceError
+
+// OnError 注册错误回调，provider后台监听、重连、解码以及bridge合并、校验中的失败都会以SourceError回调
+func (b *Bridge) OnError(fn func(SourceError)) {
+	b.handlerMu.Lock()
+	defer b.handlerMu.Unlock()
+	b.errHandlers = append(b.errHandlers, fn)
+}
+
+// ReportError 实现mediator.ErrorReporter，接收provider上报的错误（provider已自行记录日志）
+func (b *Bridge) ReportError(key string, err error) {
+	b.mu.RLock()
+	s, ok := b.pvm[key]
+	closed := b.closed
+	b.mu.RUnlock()
+	if !ok || closed {
+		return
+	}
+	b.reportError(s.sourceError(provider.OpLoad, err))
+}
+
+func (b *Bridge) reportError(err *SourceError) {
+	b.handlerMu.RLock()
+	handlers := b.errHandlers
+	b.handlerMu.RUnlock()
+	for _, fn := range handlers {
+		fn(*err)
+	}
+}
+
+// sourceError 将err包装为该source的SourceError，err已是SourceError时原样返回
+func (s *source) sourceError(op provider.Op, err error) *SourceError {
+	var serr *SourceError
+	if errors.As(err, &serr) {
+		return serr
+	}
+	return &SourceError{
+		Key:      s.key,
+		Provider: s.cfg.Provider,
+		Op:       op,
+		Err:      err,
+	}
+}
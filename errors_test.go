@@ -0,0 +1,64 @@
+package confremote_pilot
+
+import (
+	"context"
+	"errors"
+	"github.com/fufuzion/confremote-pilot/logger"
+	"github.com/fufuzion/confremote-pilot/provider"
+	"testing"
+)
+
+func TestBridge_SentinelErrors(t *testing.T) {
+	b, _ := newFakeBridge(t)
+	if err := b.RegisterSource("a", fakeConfig(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RegisterSource("a", fakeConfig(nil)); !errors.Is(err, ErrSourceExists) {
+		t.Errorf("duplicate register err = %v, want ErrSourceExists", err)
+	}
+	if err := b.UnregisterSource("x"); !errors.Is(err, ErrSourceNotFound) {
+		t.Errorf("unregister err = %v, want ErrSourceNotFound", err)
+	}
+	_ = b.Close()
+	if err := b.RegisterSource("b", fakeConfig(nil)); !errors.Is(err, ErrBridgeClosed) {
+		t.Errorf("register after close err = %v, want ErrBridgeClosed", err)
+	}
+
+	// 使用真实的provider工厂
+	nb := New(context.Background(), WithLogger(logger.Nop()))
+	defer nb.Close()
+	err := nb.RegisterSource("x", &Config{Provider: "bogus"})
+	if !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("unknown provider err = %v, want ErrUnknownProvider", err)
+	}
+}
+
+func TestBridge_OnError(t *testing.T) {
+	b, f := newFakeBridge(t)
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{"name": "a"})); err != nil {
+		t.Fatal(err)
+	}
+	var got []SourceError
+	b.OnError(func(err SourceError) {
+		got = append(got, err)
+	})
+
+	boom := errors.New("boom")
+	f.get("a").failLoad(boom)
+	if b.Get("name") != "a" {
+		t.Error("failed load must keep the current config")
+	}
+	watchErr := &SourceError{Key: "a", Provider: fakeProviderType, Op: provider.OpWatch, Err: boom}
+	b.coordinator.NotifyError("a", watchErr)
+	b.coordinator.NotifyError("unknown", boom)
+
+	if len(got) != 2 {
+		t.Fatalf("errors = %v, want 2", got)
+	}
+	if got[0].Key != "a" || got[0].Op != provider.OpLoad || !errors.Is(&got[0], boom) {
+		t.Errorf("load error = %+v", got[0])
+	}
+	if got[1].Op != provider.OpWatch {
+		t.Errorf("watch error op = %s", got[1].Op)
+	}
+}
@@ -0,0 +1,56 @@
+package confremote_pilot
+
+import (
+	"sort"
+	"time"
+)
+
+type EventType int
+
+const (
+	EventChanged          EventType = iota // 合并视图已更新
+	EventResync                            // 事件流因缓冲区溢出丢弃了事件，差异字段为空，消费方应重新读取全量配置
+	EventValidationFailed                  // 候选配置被Validator拒绝，视图保持不变，差异字段为被拒绝的变更
+	EventStale                             // 远端不可用，Source改用本地缓存的配置注册，差异字段为缓存带来的变更
+	EventRecovered                         // Source的远端已恢复，缓存的配置被远端的配置替换
+	EventRolledBack                        // 视图被Rollback固定为RolledBackTo对应的历史内容
+	EventUnpinned                          // 视图解除固定，按各source当前的配置重新合并；由source变更解除时Source为该source
+)
+
+// KeyChange 单个配置项的变化，Key为小写的完整路径，如"db.host"
+type KeyChange struct {
+	Key      string
+	OldValue any
+	NewValue any
+}
+
+// ChangeEvent 一次合并视图更新前后的差异，只比较叶子节点，slice整体视为一个值
+type ChangeEvent struct {
+	Type         EventType
+	Generation   uint64 // 更新后视图的generation；EventValidationFailed时为仍在生效的视图的generation
+	Source       string // 触发本次更新的source key，批量注册、调整优先级时为空
+	Provider     string // 触发本次更新的provider类型
+	Revision     string // 触发本次更新的source的远端版本
+	Added        []KeyChange
+	Removed      []KeyChange
+	Modified     []KeyChange
+	Time         time.Time
+	Err          error  // Type为EventValidationFailed时的校验错误
+	RolledBackTo uint64 // Type为EventRolledBack时回滚到的generation
+}
+
+func (e ChangeEvent) Empty() bool {
+	return len(e.Added) == 0 && len(e.Removed) == 0 && len(e.Modified) == 0
+}
+
+// Keys 返回所有发生变化的key，已排序
+func (e ChangeEvent) Keys() []string {
+	keys := make([]string, 0, len(e.Added)+len(e.Removed)+len(e.Modified))
+	for _, changes := range [][]KeyChange{e.Added, e.Removed, e.Modified} {
+		for _, c := range changes {
+			keys = append(keys, c.Key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,58 @@
+package confremote_pilot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSnapshot_Diff(t *testing.T) {
+	before := map[string]any{
+		"db":   map[string]any{"host": "a", "port": 1},
+		"tags": []any{"x"},
+		"gone": true,
+	}
+	after := map[string]any{
+		"db":   map[string]any{"host": "b", "port": 1, "user": "root"},
+		"tags": []any{"x"},
+	}
+	added, removed, modified := snapshotOf(t, before).Diff(snapshotOf(t, after))
+	if want := []KeyChange{{Key: "db.user", NewValue: "root"}}; !reflect.DeepEqual(added, want) {
+		t.Errorf("added = %v, want %v", added, want)
+	}
+	if want := []KeyChange{{Key: "gone", OldValue: true}}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+	if want := []KeyChange{{Key: "db.host", OldValue: "a", NewValue: "b"}}; !reflect.DeepEqual(modified, want) {
+		t.Errorf("modified = %v, want %v", modified, want)
+	}
+}
+
+func TestBridge_OnChange(t *testing.T) {
+	b, f := newFakeBridge(t)
+	var events []ChangeEvent
+	b.OnChange(func(ev ChangeEvent) {
+		events = append(events, ev)
+	})
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{"name": "a", "port": 1})); err != nil {
+		t.Fatal(err)
+	}
+	f.get("a").push(map[string]any{"name": "a", "port": 1})
+	f.get("a").push(map[string]any{"name": "a2"})
+	if len(events) != 2 {
+		t.Fatalf("events = %d, want 2 (unchanged push must not publish)", len(events))
+	}
+	ev := events[1]
+	if ev.Source != "a" || ev.Provider != fakeProviderType.ToString() || ev.Revision != "2" {
+		t.Errorf("event meta = %s/%s/%s", ev.Source, ev.Provider, ev.Revision)
+	}
+	if got, want := ev.Keys(), []string{"name", "port"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+	if err := b.UnregisterSource("a"); err != nil {
+		t.Fatal(err)
+	}
+	b.flush()
+	if ev := events[len(events)-1]; len(ev.Removed) != 1 || ev.Removed[0].Key != "name" {
+		t.Errorf("unregister event removed = %v", ev.Removed)
+	}
+}
@@ -0,0 +1,128 @@
+package confremote_pilot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fufuzion/confremote-pilot/provider"
+	"reflect"
+	"strings"
+)
+
+var ErrKeyNotFound = errors.New("key not found")
+
+// Provenance 某个source对一个key提供的值及该source的来源信息
+type Provenance struct {
+	Source   string             // 注册source时使用的key
+	Provider string             // provider类型
+	Sources  []*provider.Source // nacos提供该值的dataId/group；provider未实现PartLoader时为Config中的全部dataId
+	Path     string             // zookeeper/etcd/consul/firestore/offline的配置路径
+	Revision string             // 远端版本，能确定dataId时为该dataId的版本
+	Priority int
+	Stale    bool // 正在使用本地缓存的配置
+	Value    any  // 该source中key的原始值
+}
+
+// Explanation Explain的结果
+type Explanation struct {
+	Key        string
+	Value      any          // 合并视图中的值
+	Winner     Provenance   // 提供该值的source
+	Overridden []Provenance // 同样包含该key、被覆盖的低优先级source，按合并顺序从高到低
+}
+
+// Explain 返回key在合并视图中的值由哪个source提供，以及被覆盖的低优先级source及其值。
+// key为中间节点且使用MergeDeep时，子树可能由多个source共同组成，Winner为其中优先级最高的一个；
+// 视图被Rollback固定、Freeze或暂存变更尚未生效时，按各source已合并的配置解释
+func (b *Bridge) Explain(key string) (Explanation, error) {
+	snap := b.Snapshot()
+	if !snap.IsSet(key) {
+		return Explanation{}, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	segments := strings.Split(strings.ToLower(key), ".")
+	b.mu.RLock()
+	ordered := b.ordered()
+	var found []Provenance
+	for _, s := range ordered {
+		v, ok := lookup(s.settings, segments)
+		if !ok {
+			continue
+		}
+		found = append(found, provenanceOf(s, segments, v))
+	}
+	b.mu.RUnlock()
+	if len(found) == 0 {
+		return Explanation{}, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	ex := Explanation{
+		Key:    strings.ToLower(key),
+		Value:  snap.Get(key),
+		Winner: found[len(found)-1],
+	}
+	for i := len(found) - 2; i >= 0; i-- {
+		ex.Overridden = append(ex.Overridden, found[i])
+	}
+	return ex, nil
+}
+
+// provenanceOf 调用方需持有b.mu
+func provenanceOf(s *source, segments []string, v any) Provenance {
+	p := Provenance{
+		Source:   s.key,
+		Provider: s.cfg.Provider.ToString(),
+		Sources:  s.cfg.Sources,
+		Revision: s.pv.Revision(),
+		Priority: s.priority,
+		Stale:    s.stale,
+		Value:    v,
+	}
+	p.Path, _ = s.cfg.Properties["path"].(string)
+	if part, ok := winningPart(s, segments, v); ok {
+		if part.Source != nil {
+			p.Sources = []*provider.Source{part.Source}
+		}
+		if part.Path != "" {
+			p.Path = part.Path
+		}
+		p.Revision = part.Revision
+	}
+	return p
+}
+
+// winningPart 在provider的各dataId中找出提供该值的一个：靠后的dataId覆盖靠前的，取最后一个包含该key的；
+// 其值与source已合并的配置不一致时（Freeze或暂存变更尚未生效）无法确定，返回false
+func winningPart(s *source, segments []string, v any) (provider.Part, bool) {
+	pl, ok := s.pv.(provider.PartLoader)
+	if !ok {
+		return provider.Part{}, false
+	}
+	parts := pl.LoadParts()
+	for i := len(parts) - 1; i >= 0; i-- {
+		pv, ok := lookup(parts[i].Settings, segments)
+		if !ok {
+			continue
+		}
+		return parts[i], reflect.DeepEqual(pv, v)
+	}
+	return provider.Part{}, false
+}
+
+// lookup 在source的原始配置中按路径查找，不区分大小写；配置中的key本身含"."时同样可以命中
+func lookup(m map[string]any, segments []string) (any, bool) {
+	for n := len(segments); n > 0; n-- {
+		prefix := strings.Join(segments[:n], ".")
+		for k, v := range m {
+			if !strings.EqualFold(k, prefix) {
+				continue
+			}
+			if n == len(segments) {
+				return v, true
+			}
+			if sub, ok := v.(map[string]any); ok {
+				if ret, ok := lookup(sub, segments[n:]); ok {
+					return ret, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
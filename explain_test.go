@@ -0,0 +1,87 @@
+package confremote_pilot
+
+import (
+	"errors"
+	"github.com/fufuzion/confremote-pilot/provider"
+	"testing"
+)
+
+func TestBridge_Explain(t *testing.T) {
+	b, f := newFakeBridge(t)
+	base := fakeConfig(map[string]any{"DB": map[string]any{"Host": "base", "port": 1}, "n": 0})
+	base.Properties["path"] = "/conf/base"
+	if err := b.RegisterSource("base", base); err != nil {
+		t.Fatal(err)
+	}
+	high := fakeConfig(map[string]any{"db.host": "high"})
+	high.Priority = 10
+	if err := b.RegisterSource("high", high); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RegisterSource("mid", fakeConfig(map[string]any{"db": map[string]any{"host": "mid"}})); err != nil {
+		t.Fatal(err)
+	}
+	f.get("mid").push(map[string]any{"db": map[string]any{"host": "mid2"}})
+
+	ex, err := b.Explain("DB.host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ex.Key != "db.host" || ex.Value != "high" || ex.Winner.Source != "high" || ex.Winner.Value != "high" || ex.Winner.Priority != 10 {
+		t.Errorf("explanation = %+v", ex)
+	}
+	if len(ex.Overridden) != 2 {
+		t.Fatalf("overridden = %+v, want mid and base", ex.Overridden)
+	}
+	if o := ex.Overridden[0]; o.Source != "mid" || o.Value != "mid2" || o.Revision != "1" || o.Provider != fakeProviderType.ToString() {
+		t.Errorf("overridden[0] = %+v", o)
+	}
+	if o := ex.Overridden[1]; o.Source != "base" || o.Value != "base" || o.Path != "/conf/base" {
+		t.Errorf("overridden[1] = %+v", o)
+	}
+
+	if ex, err = b.Explain("db.port"); err != nil || ex.Winner.Source != "base" || len(ex.Overridden) != 0 {
+		t.Errorf("explanation = %+v, err = %v", ex, err)
+	}
+	if _, err = b.Explain("db.missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("err = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestBridge_ExplainParts(t *testing.T) {
+	b, f := newFakeBridge(t)
+	first := provider.Part{
+		Source:   &provider.Source{DataId: "a.yaml", Group: "g"},
+		Revision: "r1",
+		Settings: map[string]any{"db": map[string]any{"host": "a"}, "port": 1},
+	}
+	second := provider.Part{
+		Source:   &provider.Source{DataId: "b.yaml", Group: "g"},
+		Revision: "r2",
+		Settings: map[string]any{"port": 2},
+	}
+	if err := b.RegisterSource("app", fakePartsConfig(first, second)); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]provider.Part{"db.host": first, "port": second} {
+		ex, err := b.Explain(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w := ex.Winner; len(w.Sources) != 1 || w.Sources[0] != want.Source || w.Revision != want.Revision {
+			t.Errorf("%s winner = %+v, want %s at %s", key, w, want.Source.DataId, want.Revision)
+		}
+	}
+
+	// 冻结期间dataId的内容已变化，无法确定时不指向任何一个dataId
+	b.Freeze("test")
+	second.Settings = map[string]any{"port": 3}
+	f.get("app").pushParts(first, second)
+	ex, err := b.Explain("port")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ex.Value != 2 || len(ex.Winner.Sources) != 0 {
+		t.Errorf("frozen winner = %+v, want no dataId", ex.Winner)
+	}
+}
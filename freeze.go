@@ -0,0 +1,120 @@
+package confremote_pilot
+
+import (
+	"github.com/fufuzion/confremote-pilot/logger"
+	"github.com/fufuzion/confremote-pilot/provider"
+	"time"
+)
+
+// Freeze 暂停应用远端变更，provider保持连接：冻结期间的变更通知仍会读取各source的最新配置，
+// 可通过FrozenDiff查看，但不合并到视图，直到Thaw时作为一次更新生效；注册、注销等主动操作不受影响
+func (b *Bridge) Freeze(reason string) {
+	b.mu.Lock()
+	if !b.frozen {
+		b.frozen = true
+		b.frozenAt = time.Now()
+	}
+	b.freezeReason = reason
+	b.mu.Unlock()
+	b.o.logger.Warn("remote updates frozen", "reason", reason)
+}
+
+// Frozen 返回冻结的原因与开始时间，未冻结时ok为false
+func (b *Bridge) Frozen() (reason string, since time.Time, ok bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.freezeReason, b.frozenAt, b.frozen
+}
+
+// hold 冻结期间读取s的最新配置暂存，不合并到视图，调用方需持有b.mu写锁
+func (b *Bridge) hold(s *source, msg map[string]any) error {
+	setting, err := s.pv.Load()
+	if err != nil {
+		return s.sourceError(provider.OpLoad, err)
+	}
+	s.held = deepCopyMap(setting)
+	s.approved = false
+	if s.heldMsg == nil {
+		s.heldMsg = make(map[string]any, len(msg))
+	}
+	for k, v := range msg {
+		s.heldMsg[k] = v
+	}
+	return nil
+}
+
+// FrozenDiff 返回Thaw时将要应用的变化，Source为唯一有暂存配置的source，多个时为空；
+// 未冻结或没有暂存的变更时差异为空
+func (b *Bridge) FrozenDiff() (ChangeEvent, error) {
+	b.mu.RLock()
+	before := b.Snapshot()
+	ordered := b.ordered()
+	settings := make([]map[string]any, 0, len(ordered))
+	var held []*source
+	for _, s := range ordered {
+		if s.held != nil {
+			held = append(held, s)
+			settings = append(settings, s.held)
+			continue
+		}
+		settings = append(settings, s.settings)
+	}
+	var trigger *source
+	if len(held) == 1 {
+		trigger = held[0]
+	}
+	ev := newChangeEvent(EventChanged, trigger, before.gen)
+	b.mu.RUnlock()
+	if len(held) == 0 {
+		return ev, nil
+	}
+	tree, err := mergeTree(b.o.mergeStrategy, settings...)
+	if err != nil {
+		return ev, err
+	}
+	ev.Added, ev.Removed, ev.Modified = before.Diff(newSnapshot(tree))
+	return ev, nil
+}
+
+// Thaw 解除冻结，把冻结期间暂存的各source最新配置作为一次更新合并到视图；
+// 校验失败时视图保持不变并分发EventValidationFailed，暂存的配置随之丢弃。
+// 设置了ApplyPolicy时，差异命中延迟或审批策略的source按策略暂存，需审批的变更仍需Approve，其余source仍作为一次更新合并
+func (b *Bridge) Thaw() error {
+	b.mu.Lock()
+	if !b.frozen {
+		b.mu.Unlock()
+		return nil
+	}
+	b.frozen = false
+	since := b.frozenAt
+	b.freezeReason, b.frozenAt = "", time.Time{}
+	var (
+		held     []*source
+		next     []map[string]any
+		msgs     []map[string]any
+		approved []bool
+	)
+	for _, s := range b.ordered() {
+		if s.held == nil {
+			continue
+		}
+		held = append(held, s)
+		next = append(next, s.held)
+		msgs = append(msgs, s.heldMsg)
+		approved = append(approved, s.approved)
+		s.held, s.heldMsg, s.approved = nil, nil, false
+	}
+	if len(held) == 0 {
+		b.mu.Unlock()
+		b.o.logger.Info("remote updates thawed", "frozen_for", time.Since(since))
+		return nil
+	}
+	err := b.applyBatch(held, next, msgs, approved)
+	b.mu.Unlock()
+	if err != nil {
+		b.o.logger.Error("apply frozen changes failed", logger.KeyError, err)
+		return err
+	}
+	b.o.logger.Info("remote updates thawed", "frozen_for", time.Since(since), "sources", len(held))
+	return nil
+}
@@ -0,0 +1,184 @@
+package confremote_pilot
+
+import (
+	"errors"
+	"github.com/spf13/viper"
+	"sync"
+	"testing"
+)
+
+func TestBridge_Freeze(t *testing.T) {
+	b, f := newFakeBridge(t)
+	var (
+		mu     sync.Mutex
+		events []ChangeEvent
+		msgs   []map[string]any
+	)
+	b.OnChange(func(ev ChangeEvent) {
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+	})
+	b.SetHook(func(key string, msg map[string]any) {
+		mu.Lock()
+		msgs = append(msgs, msg)
+		mu.Unlock()
+	})
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{"n": 0, "x": 0})); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RegisterSource("b", fakeConfig(map[string]any{"m": 0})); err != nil {
+		t.Fatal(err)
+	}
+	b.flush()
+	gen := b.Generation()
+	b.Freeze("deploy")
+	if reason, _, ok := b.Frozen(); !ok || reason != "deploy" {
+		t.Errorf("frozen = %q %v", reason, ok)
+	}
+	f.get("a").push(map[string]any{"n": 1, "x": 0})
+	f.get("a").push(map[string]any{"n": 2})
+	f.get("b").push(map[string]any{"m": 1})
+	if b.Generation() != gen || b.Get("n") != 0 || b.Get("m") != 0 {
+		t.Fatalf("view changed while frozen: %v", b.All())
+	}
+	ev, err := b.FrozenDiff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ev.Modified) != 2 || len(ev.Removed) != 1 || ev.Source != "" {
+		t.Errorf("frozen diff = %+v", ev)
+	}
+	if f.get("a").isClosed() {
+		t.Error("provider must stay connected")
+	}
+
+	if err = b.Thaw(); err != nil {
+		t.Fatal(err)
+	}
+	b.flush()
+	if _, _, ok := b.Frozen(); ok {
+		t.Error("still frozen after Thaw")
+	}
+	if b.Generation() != gen+1 || b.Get("n") != 2 || b.Get("m") != 1 || b.Snapshot().IsSet("x") {
+		t.Errorf("tree = %v at generation %d, want one update to the latest state", b.All(), b.Generation()-gen)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if ev = events[len(events)-1]; ev.Type != EventChanged || len(ev.Modified) != 2 || len(ev.Removed) != 1 {
+		t.Errorf("thaw event = %+v", ev)
+	}
+	if len(msgs) != 2 || len(msgs[0]) != 2 {
+		t.Errorf("hook msgs = %v, want one merged msg per source", msgs)
+	}
+}
+
+func TestBridge_ThawRejected(t *testing.T) {
+	errNeg := errors.New("negative")
+	b, f := newFakeBridge(t, WithValidator(func(newCfg, _ *viper.Viper) error {
+		if newCfg.GetInt("n") < 0 {
+			return errNeg
+		}
+		return nil
+	}))
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{"n": 0})); err != nil {
+		t.Fatal(err)
+	}
+	b.Freeze("incident")
+	f.get("a").push(map[string]any{"n": -1})
+	if err := b.Thaw(); !errors.Is(err, errNeg) {
+		t.Errorf("err = %v, want validation error", err)
+	}
+	if b.Get("n") != 0 {
+		t.Errorf("n = %v, want 0", b.Get("n"))
+	}
+	f.get("a").push(map[string]any{"n": 3})
+	if b.Get("n") != 3 {
+		t.Errorf("n = %v after thaw, want 3", b.Get("n"))
+	}
+}
+
+func TestBridge_ThawApplyPolicy(t *testing.T) {
+	b, f := newFakeBridge(t, WithApplyPolicy("db", ApplyPolicy{Mode: ApplyApproval}))
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{"db": map[string]any{"host": "h0"}, "n": 0})); err != nil {
+		t.Fatal(err)
+	}
+	b.Freeze("incident")
+	f.get("a").push(map[string]any{"db": map[string]any{"host": "evil"}, "n": 0})
+	if err := b.Thaw(); err != nil {
+		t.Fatal(err)
+	}
+	b.flush()
+	pending := b.PendingChanges()
+	if len(pending) != 1 || b.Get("db.host") != "h0" {
+		t.Fatalf("pending = %+v, db.host = %v, want the frozen change to wait for approval", pending, b.Get("db.host"))
+	}
+
+	// 已暂存、尚未批准的变更不会随冻结期间的其他变更生效
+	b.Freeze("incident")
+	f.get("a").push(map[string]any{"db": map[string]any{"host": "evil"}, "n": 1})
+	if err := b.Thaw(); err != nil {
+		t.Fatal(err)
+	}
+	b.flush()
+	pending = b.PendingChanges()
+	if len(pending) != 1 || b.Get("db.host") != "h0" || b.Get("n") != 0 {
+		t.Fatalf("pending = %+v, tree = %v, want the staged change still pending", pending, b.All())
+	}
+
+	// 冻结期间批准的变更在解冻时直接生效
+	b.Freeze("incident")
+	if err := b.Approve(pending[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Thaw(); err != nil {
+		t.Fatal(err)
+	}
+	b.flush()
+	if len(b.PendingChanges()) != 0 || b.Get("db.host") != "evil" || b.Get("n") != 1 {
+		t.Errorf("pending = %+v, tree = %v, want the approved change applied", b.PendingChanges(), b.All())
+	}
+}
+
+func TestBridge_ThawApplyPolicySingleUpdate(t *testing.T) {
+	b, f := newFakeBridge(t, WithApplyPolicy("db", ApplyPolicy{Mode: ApplyApproval}))
+	var (
+		mu     sync.Mutex
+		events []ChangeEvent
+	)
+	b.OnChange(func(ev ChangeEvent) {
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+	})
+	for _, key := range []string{"a", "b", "c"} {
+		if err := b.RegisterSource(key, fakeConfig(map[string]any{key: 0})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.flush()
+	mu.Lock()
+	events = nil
+	mu.Unlock()
+	gen := b.Generation()
+
+	b.Freeze("incident")
+	f.get("a").push(map[string]any{"a": 1})
+	f.get("b").push(map[string]any{"b": 1})
+	f.get("c").push(map[string]any{"c": 1, "db": map[string]any{"host": "evil"}})
+	if err := b.Thaw(); err != nil {
+		t.Fatal(err)
+	}
+	b.flush()
+	if b.Generation() != gen+1 || b.Get("a") != 1 || b.Get("b") != 1 {
+		t.Errorf("generation = %d, tree = %v, want a and b applied as one update", b.Generation(), b.All())
+	}
+	if pending := b.PendingChanges(); len(pending) != 1 || pending[0].Source != "c" || b.Get("c") != 0 {
+		t.Errorf("pending = %+v, want only c staged", pending)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 1 || len(events[0].Modified) != 2 {
+		t.Errorf("events = %+v, want a single event for a and b", events)
+	}
+}
@@ -0,0 +1,156 @@
+package confremote_pilot
+
+import (
+	"context"
+	"errors"
+	"github.com/fufuzion/confremote-pilot/logger"
+	"github.com/fufuzion/confremote-pilot/mediator"
+	"github.com/fufuzion/confremote-pilot/provider"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+const fakeProviderType provider.CfgProviderType = "fake"
+
+// fakeProvider 内存中的provider，用于不依赖远端服务的单元测试
+type fakeProvider struct {
+	mu          sync.RWMutex
+	key         string
+	data        map[string]any
+	parts       []provider.Part // 非空时模拟nacos的多个dataId，data为其合并结果
+	revision    int
+	closed      bool
+	loads       int
+	loadErr     error
+	coordinator *mediator.Coordinator
+	flush       func() // 等待Bridge的事件循环处理完通知
+}
+
+func (p *fakeProvider) Name() string {
+	return fakeProviderType.ToString()
+}
+
+func (p *fakeProvider) Load() (map[string]any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loads++
+	if p.loadErr != nil {
+		return nil, p.loadErr
+	}
+	return deepCopyMap(p.data), nil
+}
+
+func (p *fakeProvider) LoadParts() []provider.Part {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.parts
+}
+
+func (p *fakeProvider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+func (p *fakeProvider) Health() provider.HealthStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return provider.HealthStatus{Connected: !p.closed}
+}
+
+func (p *fakeProvider) Revision() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return strconv.Itoa(p.revision)
+}
+
+func (p *fakeProvider) isClosed() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.closed
+}
+
+func (p *fakeProvider) loadCount() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.loads
+}
+
+func (p *fakeProvider) failLoad(err error) {
+	p.mu.Lock()
+	p.loadErr = err
+	p.mu.Unlock()
+	p.coordinator.Notify(p.key, nil)
+	p.flush()
+}
+
+func (p *fakeProvider) push(data map[string]any) {
+	p.mu.Lock()
+	p.data = data
+	p.revision++
+	p.mu.Unlock()
+	p.coordinator.Notify(p.key, data)
+	p.flush()
+}
+
+// pushParts 更新各dataId的内容，data随之更新为合并结果
+func (p *fakeProvider) pushParts(parts ...provider.Part) {
+	data := provider.MergeParts(parts)
+	p.mu.Lock()
+	p.parts = parts
+	p.data = data
+	p.revision++
+	p.mu.Unlock()
+	p.coordinator.Notify(p.key, data)
+	p.flush()
+}
+
+// fakeBackend 记录每个key最近一次创建的fakeProvider
+type fakeBackend struct {
+	mu        sync.Mutex
+	providers map[string]*fakeProvider
+}
+
+func (f *fakeBackend) get(key string) *fakeProvider {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.providers[key]
+}
+
+func newFakeBridge(t *testing.T, opts ...BridgeOption) (*Bridge, *fakeBackend) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	b := New(ctx, append([]BridgeOption{WithLogger(logger.Nop())}, opts...)...)
+	f := &fakeBackend{providers: make(map[string]*fakeProvider)}
+	b.factory = func(ctx context.Context, key string, cfg *Config) (provider.Provider, error) {
+		if err, ok := cfg.Properties["err"].(string); ok {
+			return nil, errors.New(err)
+		}
+		data, _ := cfg.Properties["data"].(map[string]any)
+		parts, _ := cfg.Properties["parts"].([]provider.Part)
+		p := &fakeProvider{key: key, data: data, parts: parts, coordinator: b.coordinator, flush: b.flush}
+		f.mu.Lock()
+		f.providers[key] = p
+		f.mu.Unlock()
+		return p, nil
+	}
+	return b, f
+}
+
+func fakeConfig(data map[string]any) *Config {
+	return &Config{
+		Provider:   fakeProviderType,
+		Properties: map[string]interface{}{"data": data},
+	}
+}
+
+// fakePartsConfig 由多个dataId合并而成的source
+func fakePartsConfig(parts ...provider.Part) *Config {
+	return &Config{
+		Provider:   fakeProviderType,
+		Properties: map[string]interface{}{"data": provider.MergeParts(parts), "parts": parts},
+	}
+}
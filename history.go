@@ -0,0 +1,142 @@
+package confremote_pilot
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrGenerationNotFound = errors.New("generation not in history")
+
+// defaultHistorySize 默认保留的历史视图个数
+const defaultHistorySize = 16
+
+// WithHistory 保留最近n个合并视图供History、Rollback使用，默认16，n<=0时不保留
+func WithHistory(n int) BridgeOption {
+	return func(o *bridgeOption) {
+		o.history = max(n, 0)
+	}
+}
+
+// HistoryEntry 一次提交的合并视图及其来源，Snapshot与Revisions与bridge共享，不得修改
+type HistoryEntry struct {
+	Generation uint64
+	Type       EventType
+	Source     string            // 触发本次提交的source key，批量注册、调整优先级、Unpin时为空
+	Revisions  map[string]string // 提交时各source的远端版本
+	Snapshot   *Snapshot
+	Time       time.Time
+	prev       *Snapshot
+}
+
+// Diff 返回相对上一个视图的变化，结果按key排序
+func (e HistoryEntry) Diff() (added, removed, modified []KeyChange) {
+	return e.prev.Diff(e.Snapshot)
+}
+
+// History 返回保留的历史视图，按generation升序
+func (b *Bridge) History() []HistoryEntry {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]HistoryEntry(nil), b.history...)
+}
+
+// HistoryAt 返回generation为gen的历史视图，不在保留范围内时返回ErrGenerationNotFound
+func (b *Bridge) HistoryAt(gen uint64) (HistoryEntry, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.historyAt(gen)
+}
+
+func (b *Bridge) historyAt(gen uint64) (HistoryEntry, error) {
+	for _, e := range b.history {
+		if e.Generation == gen {
+			return e, nil
+		}
+	}
+	return HistoryEntry{}, fmt.Errorf("%w: %d", ErrGenerationNotFound, gen)
+}
+
+// recordLocked 把ev对应的视图追加到历史，超出容量时丢弃最早的，调用方需持有b.mu写锁；
+// revisions为nil时记录当前各source的版本
+func (b *Bridge) recordLocked(ev ChangeEvent, before, after *Snapshot, revisions map[string]string) {
+	if b.o.history <= 0 {
+		return
+	}
+	if revisions == nil {
+		revisions = make(map[string]string, len(b.pvm))
+		for key, s := range b.pvm {
+			revisions[key] = s.pv.Revision()
+		}
+	}
+	e := HistoryEntry{
+		Generation: after.gen,
+		Type:       ev.Type,
+		Source:     ev.Source,
+		Revisions:  revisions,
+		Snapshot:   after,
+		Time:       ev.Time,
+		prev:       before,
+	}
+	if len(b.history) < b.o.history {
+		b.history = append(b.history, e)
+		return
+	}
+	copy(b.history, b.history[1:])
+	b.history[len(b.history)-1] = e
+}
+
+// Rollback 把视图固定为历史中generation为gen的内容（以新的generation提交，EventRolledBack），
+// 直到调用Unpin或任一source再次变更（EventUnpinned）；固定期间source的变更仍会被读取
+func (b *Bridge) Rollback(gen uint64) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBridgeClosed
+	}
+	target, err := b.historyAt(gen)
+	if err != nil {
+		b.mu.Unlock()
+		return err
+	}
+	before := b.Snapshot()
+	after := newSnapshot(target.Snapshot.tree) // tree不可变，可直接共享
+	after.gen = before.gen + 1
+	b.snap.Store(after)
+	b.pinned = gen
+	ev := newChangeEvent(EventRolledBack, nil, after.gen)
+	ev.RolledBackTo = gen
+	b.recordLocked(ev, before, after, target.Revisions)
+	b.enqueue(func() {
+		b.publish(ev, before, after)
+	})
+	b.mu.Unlock()
+	b.o.logger.Warn("config rolled back", "generation", gen, "pinned_as", after.gen)
+	return nil
+}
+
+// Unpin 解除Rollback的固定，按各source当前的配置重新合并（EventUnpinned），未固定时为空操作
+func (b *Bridge) Unpin() error {
+	b.mu.Lock()
+	if b.pinned == 0 {
+		b.mu.Unlock()
+		return nil
+	}
+	pinned := b.pinned
+	before := b.Snapshot()
+	if err := b.rebuild("", nil); err != nil {
+		b.mu.Unlock()
+		return err
+	}
+	b.commitAs(EventUnpinned, nil, before, nil)
+	b.mu.Unlock()
+	b.o.logger.Info("config unpinned", "generation", pinned)
+	return nil
+}
+
+// Pinned 返回Rollback固定的generation，未固定时ok为false
+func (b *Bridge) Pinned() (gen uint64, ok bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.pinned, b.pinned != 0
+}
@@ -0,0 +1,131 @@
+package confremote_pilot
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestBridge_History(t *testing.T) {
+	b, f := newFakeBridge(t, WithHistory(3))
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{"n": 0})); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 4; i++ {
+		f.get("a").push(map[string]any{"n": i})
+	}
+	h := b.History()
+	if len(h) != 3 || h[0].Generation != 3 || h[2].Generation != 5 {
+		t.Fatalf("history = %+v, want generations 3..5", h)
+	}
+	e, err := b.HistoryAt(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Source != "a" || e.Revisions["a"] != "3" || e.Snapshot.Get("n") != 3 {
+		t.Errorf("entry = %+v", e)
+	}
+	if _, _, modified := e.Diff(); len(modified) != 1 || modified[0].OldValue != 2 {
+		t.Errorf("diff = %v", modified)
+	}
+	if _, err = b.HistoryAt(1); !errors.Is(err, ErrGenerationNotFound) {
+		t.Errorf("err = %v, want ErrGenerationNotFound", err)
+	}
+}
+
+func TestBridge_Rollback(t *testing.T) {
+	b, f := newFakeBridge(t)
+	var (
+		mu     sync.Mutex
+		events []ChangeEvent
+	)
+	b.OnChange(func(ev ChangeEvent) {
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+	})
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{"n": 0})); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RegisterSource("b", fakeConfig(map[string]any{"m": 0})); err != nil {
+		t.Fatal(err)
+	}
+	f.get("a").push(map[string]any{"n": 1})
+	if err := b.Rollback(2); err != nil {
+		t.Fatal(err)
+	}
+	b.flush()
+	if got, ok := b.Pinned(); !ok || got != 2 {
+		t.Errorf("pinned = %d %v, want 2", got, ok)
+	}
+	if b.Get("n") != 0 || b.Generation() != 4 {
+		t.Errorf("n = %v at generation %d, want 0 at 4", b.Get("n"), b.Generation())
+	}
+	mu.Lock()
+	ev := events[len(events)-1]
+	mu.Unlock()
+	if ev.Type != EventRolledBack || ev.RolledBackTo != 2 || len(ev.Modified) != 1 {
+		t.Errorf("rollback event = %+v", ev)
+	}
+
+	// 固定期间另一个source的变更解除固定，并按全部source的当前配置合并
+	f.get("b").push(map[string]any{"m": 1})
+	if _, ok := b.Pinned(); ok {
+		t.Error("remote change must unpin")
+	}
+	if b.Get("n") != 1 || b.Get("m") != 1 {
+		t.Errorf("tree = %v after unpin", b.All())
+	}
+	mu.Lock()
+	ev = events[len(events)-1]
+	mu.Unlock()
+	if ev.Type != EventUnpinned || ev.Source != "b" || len(ev.Modified) != 2 {
+		t.Errorf("unpin event = %+v", ev)
+	}
+
+	if err := b.Rollback(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Unpin(); err != nil {
+		t.Fatal(err)
+	}
+	b.flush()
+	if _, ok := b.Pinned(); ok || b.Get("n") != 1 {
+		t.Errorf("n = %v after Unpin, want 1", b.Get("n"))
+	}
+	mu.Lock()
+	ev = events[len(events)-1]
+	mu.Unlock()
+	if ev.Type != EventUnpinned || ev.Source != "" {
+		t.Errorf("unpin event = %+v", ev)
+	}
+	if err := b.Rollback(100); !errors.Is(err, ErrGenerationNotFound) {
+		t.Errorf("err = %v, want ErrGenerationNotFound", err)
+	}
+}
+
+func TestBridge_HistoryHiddenChange(t *testing.T) {
+	b, f := newFakeBridge(t, WithHistory(3))
+	if err := b.RegisterSource("low", fakeConfig(map[string]any{"n": 0})); err != nil {
+		t.Fatal(err)
+	}
+	high := fakeConfig(map[string]any{"n": 100})
+	high.Priority = 10
+	if err := b.RegisterSource("high", high); err != nil {
+		t.Fatal(err)
+	}
+	gen := b.Generation()
+	history := b.History()
+	for i := 1; i <= 5; i++ {
+		f.get("low").push(map[string]any{"n": i})
+	}
+	if b.Generation() != gen {
+		t.Errorf("generation = %d, want %d when the change is hidden", b.Generation(), gen)
+	}
+	if got := b.History(); len(got) != len(history) || got[len(got)-1].Generation != gen {
+		t.Errorf("history = %+v, want unchanged", got)
+	}
+	if err := b.Rollback(history[0].Generation); err != nil {
+		t.Errorf("rollback to a prior generation: %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package confremote_pilot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fufuzion/confremote-pilot/provider"
+	"sort"
+)
+
+// source 一个已注册的配置源，即合并视图中的一层
+type source struct {
+	key      string
+	cfg      *Config
+	pv       provider.Provider
+	priority int
+	seq      uint64 // 注册顺序，priority相同时后注册的覆盖先注册的
+	cancel   context.CancelFunc
+	settings map[string]any // 最近一次读取的配置（深拷贝），合并时不再调用provider的Load；只会被整体替换、不会原地修改，释放b.mu后仍可安全读取
+	deb      *debouncer     // 未开启防抖时为nil，可能与其他source共享bridge级的debouncer
+	stale    bool           // 远端不可用，以本地缓存的配置注册
+	held     map[string]any // Freeze期间读取、尚未合并的配置，见Thaw
+	heldMsg  map[string]any // Freeze期间按到达顺序浅合并的通知内容
+	approved bool           // held为冻结期间已批准或已到期的暂存变更，Thaw时不再经过ApplyPolicy
+	staged   *stagedChange  // 按ApplyPolicy暂存、尚未生效的变更
+}
+
+// load 重新读取provider的配置并缓存
+func (s *source) load() error {
+	setting, err := s.pv.Load()
+	if err != nil {
+		return s.sourceError(provider.OpLoad, err)
+	}
+	s.settings = deepCopyMap(setting)
+	return nil
+}
+
+// close 停止provider的监听并等待其goroutine退出，不能在持有b.mu时调用，
+// 否则会与正在等待b.mu的变更通知互相等待
+func (s *source) close() error {
+	s.cancel()
+	if s.staged != nil && s.staged.timer != nil {
+		s.staged.timer.Stop()
+	}
+	if s.deb != nil && !s.deb.shared {
+		s.deb.stop()
+	}
+	return s.pv.Close()
+}
+
+// Layer 合并视图中的一层，Layers按合并顺序返回，排在后面的覆盖前面的
+type Layer struct {
+	Key      string
+	Provider string
+	Priority int
+	Revision string
+	Health   provider.HealthStatus
+	Stale    bool // 正在使用本地缓存的配置，远端恢复前不会更新
+}
+
+// ordered 返回按合并顺序排列的source：priority升序，相同priority按注册顺序
+func (b *Bridge) ordered() []*source {
+	ret := make([]*source, 0, len(b.pvm))
+	for _, s := range b.pvm {
+		ret = append(ret, s)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].priority != ret[j].priority {
+			return ret[i].priority < ret[j].priority
+		}
+		return ret[i].seq < ret[j].seq
+	})
+	return ret
+}
+
+func (b *Bridge) Layers() []Layer {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	ordered := b.ordered()
+	ret := make([]Layer, 0, len(ordered))
+	for _, s := range ordered {
+		ret = append(ret, Layer{
+			Key:      s.key,
+			Provider: s.pv.Name(),
+			Priority: s.priority,
+			Revision: s.pv.Revision(),
+			Health:   s.pv.Health(),
+			Stale:    s.stale,
+		})
+	}
+	return ret
+}
+
+// SetPriority 调整单个source的优先级并重新合并
+func (b *Bridge) SetPriority(key string, priority int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	before := b.Snapshot()
+	if err := b.setPriority(key, priority); err != nil {
+		return err
+	}
+	b.commit(nil, before, nil)
+	return nil
+}
+
+func (b *Bridge) setPriority(key string, priority int) error {
+	s, ok := b.pvm[key]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrSourceNotFound, key)
+	}
+	old := s.priority
+	s.priority = priority
+	if err := b.rebuild(key, topKeys(s.settings)); err != nil {
+		s.priority = old
+		return err
+	}
+	return nil
+}
+
+// ReorderLayers 按keys给出的顺序（低→高）重新分配优先级，keys必须包含全部已注册的source
+func (b *Bridge) ReorderLayers(keys ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	before := b.Snapshot()
+	if err := b.reorderLayers(keys); err != nil {
+		return err
+	}
+	b.commit(nil, before, nil)
+	return nil
+}
+
+func (b *Bridge) reorderLayers(keys []string) error {
+	if len(keys) != len(b.pvm) {
+		return errors.New("keys must list every registered source exactly once")
+	}
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := b.pvm[key]; !ok {
+			return fmt.Errorf("%w: %s", ErrSourceNotFound, key)
+		}
+		if _, ok := seen[key]; ok {
+			return errors.New("duplicate source: " + key)
+		}
+		seen[key] = struct{}{}
+	}
+	old := make(map[string]int, len(keys))
+	for i, key := range keys {
+		old[key] = b.pvm[key].priority
+		b.pvm[key].priority = i
+	}
+	if err := b.rebuild("", nil); err != nil {
+		for key, priority := range old {
+			b.pvm[key].priority = priority
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package confremote_pilot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func layerKeys(layers []Layer) []string {
+	keys := make([]string, 0, len(layers))
+	for _, l := range layers {
+		keys = append(keys, l.Key)
+	}
+	return keys
+}
+
+func TestBridge_Priority(t *testing.T) {
+	b, f := newFakeBridge(t)
+	high := fakeConfig(map[string]any{"name": "high"})
+	high.Priority = 10
+	if err := b.RegisterSource("high", high); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RegisterSource("low", fakeConfig(map[string]any{"name": "low"})); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Get("name"); got != "high" {
+		t.Errorf("name = %v, want high", got)
+	}
+	for i := 0; i < 20; i++ {
+		f.get("low").push(map[string]any{"name": "low"})
+		if got := b.Get("name"); got != "high" {
+			t.Fatalf("after update name = %v, want high", got)
+		}
+	}
+	if got, want := layerKeys(b.Layers()), []string{"low", "high"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("layers = %v, want %v", got, want)
+	}
+
+	if err := b.SetPriority("low", 20); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Get("name"); got != "low" {
+		t.Errorf("after SetPriority name = %v, want low", got)
+	}
+	if err := b.ReorderLayers("low", "high"); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Get("name"); got != "high" {
+		t.Errorf("after ReorderLayers name = %v, want high", got)
+	}
+	if err := b.ReorderLayers("low"); err == nil {
+		t.Error("ReorderLayers with missing key should fail")
+	}
+}
+
+func TestBridge_RegisterSourceBatchOrder(t *testing.T) {
+	b, _ := newFakeBridge(t)
+	err := b.RegisterSourceBatch(map[string]*Config{
+		"c": fakeConfig(map[string]any{"name": "c"}),
+		"a": fakeConfig(map[string]any{"name": "a"}),
+		"b": fakeConfig(map[string]any{"name": "b"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := layerKeys(b.Layers()), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("layers = %v, want %v", got, want)
+	}
+	if got := b.Get("name"); got != "c" {
+		t.Errorf("name = %v, want c", got)
+	}
+}
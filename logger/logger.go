@@ -0,0 +1,67 @@
+package logger
+
+import "log/slog"
+
+// 统一的结构化字段名
+const (
+	KeySource   = "source"   // 注册source时使用的key
+	KeyProvider = "provider" // provider类型
+	KeyPath     = "path"     // zookeeper/etcd/consul/firestore的配置路径
+	KeyDataId   = "data_id"  // nacos dataId
+	KeyGroup    = "group"    // nacos group
+	KeyRevision = "revision" // 远端版本
+	KeyOp       = "op"       // 出错时的操作，见provider.Op
+	KeyError    = "error"
+)
+
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+	// With 返回附带args字段的Logger
+	With(args ...any) Logger
+}
+
+// NewSlog 以slog.Handler输出日志
+func NewSlog(h slog.Handler) Logger {
+	return &slogLogger{l: slog.New(h)}
+}
+
+// Default 使用slog.Default()的Handler
+func Default() Logger {
+	return NewSlog(slog.Default().Handler())
+}
+
+// Nop 丢弃所有日志
+func Nop() Logger {
+	return nopLogger{}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s *slogLogger) Debug(msg string, args ...any) {
+	s.l.Debug(msg, args...)
+}
+func (s *slogLogger) Info(msg string, args ...any) {
+	s.l.Info(msg, args...)
+}
+func (s *slogLogger) Warn(msg string, args ...any) {
+	s.l.Warn(msg, args...)
+}
+func (s *slogLogger) Error(msg string, args ...any) {
+	s.l.Error(msg, args...)
+}
+func (s *slogLogger) With(args ...any) Logger {
+	return &slogLogger{l: s.l.With(args...)}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any) {}
+func (nopLogger) Info(string, ...any)  {}
+func (nopLogger) Warn(string, ...any)  {}
+func (nopLogger) Error(string, ...any) {}
+func (n nopLogger) With(...any) Logger { return n }
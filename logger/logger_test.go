@@ -0,0 +1,26 @@
+package logger
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewSlog(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlog(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	l.With(KeySource, "db").Info("config loaded", KeyRevision, "3")
+	out := buf.String()
+	for _, want := range []string{"level=INFO", "msg=\"config loaded\"", "source=db", "revision=3"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output %q missing %q", out, want)
+		}
+	}
+}
+
+func TestNop(t *testing.T) {
+	l := Nop().With(KeySource, "db")
+	l.Debug("x")
+	l.Error("x", KeyError, "boom")
+}
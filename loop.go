@@ -0,0 +1,126 @@
+package confremote_pilot
+
+import "sync"
+
+// 事件循环：provider的变更通知在Bridge自己的goroutine上按到达顺序应用，视图变更的差异计算、Value刷新与缓存写入也在其上执行；
+// hook与订阅方的回调由另一个goroutine（outbox）按generation顺序分发，消费方阻塞时只会推迟后续事件的分发，不影响应用配置。
+// 每次提交新视图时generation加一，分发任务在持有b.mu时入队，因此hook看到的generation严格递增。
+
+// taskQueue 在一个goroutine上按入队顺序执行任务
+type taskQueue struct {
+	mu      sync.Mutex
+	tasks   []func()
+	running bool
+	wake    chan struct{}
+	idle    chan struct{} // 变为空闲时关闭并替换
+}
+
+func newTaskQueue() *taskQueue {
+	return &taskQueue{
+		wake: make(chan struct{}, 1),
+		idle: make(chan struct{}),
+	}
+}
+
+// enqueue 追加一项任务，不阻塞
+func (q *taskQueue) enqueue(task func()) {
+	q.mu.Lock()
+	q.tasks = append(q.tasks, task)
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run 执行任务直到stop关闭，未执行的任务随之丢弃
+func (q *taskQueue) run(stop <-chan struct{}) {
+	for {
+		q.mu.Lock()
+		var task func()
+		if len(q.tasks) > 0 {
+			task = q.tasks[0]
+			q.tasks[0] = nil
+			q.tasks = q.tasks[1:]
+			q.running = true
+		}
+		q.mu.Unlock()
+		if task == nil {
+			select {
+			case <-q.wake:
+				continue
+			case <-stop:
+				return
+			}
+		}
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		task()
+		q.mu.Lock()
+		q.running = false
+		if len(q.tasks) == 0 {
+			close(q.idle)
+			q.idle = make(chan struct{})
+		}
+		q.mu.Unlock()
+	}
+}
+
+// wait 等待队列中的任务以及它们派生的任务全部执行完，或stop关闭
+func (q *taskQueue) wait(stop <-chan struct{}) {
+	q.mu.Lock()
+	if len(q.tasks) == 0 && !q.running {
+		q.mu.Unlock()
+		return
+	}
+	idle := q.idle
+	q.mu.Unlock()
+	select {
+	case <-idle:
+	case <-stop:
+	}
+}
+
+// enqueue 向事件循环追加一项任务；视图变更的分发任务需在持有b.mu写锁时入队，以保证与generation顺序一致
+func (b *Bridge) enqueue(task func()) {
+	b.loop.enqueue(task)
+}
+
+// deliver 把hook与订阅方的回调交给outbox，只能在事件循环上调用，以保证与generation顺序一致
+func (b *Bridge) deliver(task func()) {
+	b.outbox.enqueue(task)
+}
+
+// runLoops 启动事件循环与outbox，Close或ctx结束时退出
+func (b *Bridge) runLoops() {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-b.done:
+		case <-b.ctx.Done():
+		}
+		close(stop)
+	}()
+	b.stop = stop
+	go b.loop.run(stop)
+	go b.outbox.run(stop)
+}
+
+// flush 等待事件循环中的任务以及它们派生的分发任务全部执行完
+func (b *Bridge) flush() {
+	b.flushLoop()
+	b.outbox.wait(b.stop)
+}
+
+// flushLoop 只等待事件循环，不等待订阅方消费
+func (b *Bridge) flushLoop() {
+	b.loop.wait(b.stop)
+}
+
+// Generation 当前视图的generation，每次提交新视图时加一，未注册任何source时为0
+func (b *Bridge) Generation() uint64 {
+	return b.snap.Load().gen
+}
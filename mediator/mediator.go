@@ -4,6 +4,11 @@ type Updatable interface {
 	Update(key string, msg map[string]any)
 }
 
+// ErrorReporter 由需要感知provider后台错误的Updatable实现
+type ErrorReporter interface {
+	ReportError(key string, err error)
+}
+
 type Coordinator struct {
 	updatable Updatable
 }
@@ -15,3 +20,9 @@ func NewCoordinator(up Updatable) *Coordinator {
 func (c *Coordinator) Notify(key string, msg map[string]any) {
 	c.updatable.Update(key, msg)
 }
+
+func (c *Coordinator) NotifyError(key string, err error) {
+	if r, ok := c.updatable.(ErrorReporter); ok {
+		r.ReportError(key, err)
+	}
+}
@@ -0,0 +1,129 @@
+package confremote_pilot
+
+import (
+	"github.com/spf13/viper"
+	"strings"
+)
+
+// mergeSettings 按顺序合并settings，后面的覆盖前面的；
+// 合并前先深拷贝，避免viper.MergeConfigMap改写provider持有的原始数据
+func mergeSettings(strategy MergeStrategy, settings ...map[string]any) (*viper.Viper, error) {
+	vp := viper.New()
+	switch strategy {
+	case MergeReplace:
+		merged := make(map[string]any)
+		for _, setting := range settings {
+			for k, v := range setting {
+				merged[strings.ToLower(k)] = deepCopy(v)
+			}
+		}
+		if err := vp.MergeConfigMap(merged); err != nil {
+			return nil, err
+		}
+	default:
+		for _, setting := range settings {
+			if err := vp.MergeConfigMap(deepCopyMap(setting)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return vp, nil
+}
+
+// mergeTree 全量合并settings，返回合并后的配置树
+func mergeTree(strategy MergeStrategy, settings ...map[string]any) (map[string]any, error) {
+	vp, err := mergeSettings(strategy, settings...)
+	if err != nil {
+		return nil, err
+	}
+	return vp.AllSettings(), nil
+}
+
+// topKey 返回k在合并结果中所属的顶层key，viper会把"a.b"这样的key展开到"a"之下
+func topKey(k string) string {
+	k = strings.ToLower(k)
+	if i := strings.IndexByte(k, '.'); i >= 0 {
+		return k[:i]
+	}
+	return k
+}
+
+// topKeys 返回setting涉及的全部顶层key
+func topKeys(setting map[string]any) map[string]struct{} {
+	ret := make(map[string]struct{}, len(setting))
+	for k := range setting {
+		ret[topKey(k)] = struct{}{}
+	}
+	return ret
+}
+
+// remerge 在prev的基础上只重新合并keys中的顶层key，其余顶层key的子树直接沿用prev。
+// 各顶层key的合并结果只取决于各层中属于它的条目，因此结果与mergeTree(strategy, settings...)相同；
+// 返回的树与prev共享未变化的子树
+func remerge(strategy MergeStrategy, prev map[string]any, keys map[string]struct{}, settings ...map[string]any) (map[string]any, error) {
+	parts := make(map[string][]map[string]any, len(keys))
+	for _, setting := range settings {
+		layer := make(map[string]map[string]any)
+		for k, v := range setting {
+			tk := topKey(k)
+			if _, ok := keys[tk]; !ok {
+				continue
+			}
+			if layer[tk] == nil {
+				layer[tk] = make(map[string]any)
+			}
+			layer[tk][k] = v
+		}
+		for tk, part := range layer {
+			parts[tk] = append(parts[tk], part)
+		}
+	}
+	next := make(map[string]any, len(prev)+len(keys))
+	for k, v := range prev {
+		if _, ok := keys[k]; !ok {
+			next[k] = v
+		}
+	}
+	for tk, part := range parts {
+		merged, err := mergeTree(strategy, part...)
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := merged[tk]; ok {
+			next[tk] = v
+		}
+	}
+	return next, nil
+}
+
+func deepCopyMap(m map[string]any) map[string]any {
+	if m == nil {
+		return nil
+	}
+	ret := make(map[string]any, len(m))
+	for k, v := range m {
+		ret[k] = deepCopy(v)
+	}
+	return ret
+}
+
+func deepCopy(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		return deepCopyMap(val)
+	case map[any]any:
+		ret := make(map[any]any, len(val))
+		for k, sv := range val {
+			ret[k] = deepCopy(sv)
+		}
+		return ret
+	case []any:
+		ret := make([]any, len(val))
+		for i, sv := range val {
+			ret[i] = deepCopy(sv)
+		}
+		return ret
+	default:
+		return v
+	}
+}
@@ -0,0 +1,87 @@
+package confremote_pilot
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestMergeSettings(t *testing.T) {
+	low := map[string]any{"db": map[string]any{"host": "a", "user": "root"}}
+	high := map[string]any{"DB": map[string]any{"host": "b"}}
+	tests := []struct {
+		strategy MergeStrategy
+		wantUser any
+	}{
+		{MergeDeep, "root"},
+		{MergeReplace, nil},
+	}
+	for _, tt := range tests {
+		vp, err := mergeSettings(tt.strategy, low, high)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := vp.Get("db.host"); got != "b" {
+			t.Errorf("strategy %d: db.host = %v, want b", tt.strategy, got)
+		}
+		if got := vp.Get("db.user"); got != tt.wantUser {
+			t.Errorf("strategy %d: db.user = %v, want %v", tt.strategy, got, tt.wantUser)
+		}
+	}
+	if _, ok := high["DB"]; !ok {
+		t.Error("mergeSettings modified its input")
+	}
+}
+
+// randomSetting 生成带大小写、"."分隔key、类型冲突的随机配置
+func randomSetting(r *rand.Rand, depth int) map[string]any {
+	keys := []string{"a", "B", "c", "x.y", "d"}
+	ret := make(map[string]any)
+	for _, k := range keys {
+		switch r.Intn(5) {
+		case 0:
+			ret[k] = r.Intn(3)
+		case 1:
+			if depth < 2 {
+				ret[k] = randomSetting(r, depth+1)
+			}
+		case 2:
+			ret[k] = []any{r.Intn(2)}
+		}
+	}
+	return ret
+}
+
+func TestRemerge(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, strategy := range []MergeStrategy{MergeDeep, MergeReplace} {
+		for round := 0; round < 200; round++ {
+			layers := make([]map[string]any, 1+r.Intn(4))
+			for i := range layers {
+				layers[i] = randomSetting(r, 0)
+			}
+			tree, err := mergeTree(strategy, layers...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for step := 0; step < 5; step++ {
+				i := r.Intn(len(layers))
+				keys := topKeys(layers[i])
+				layers[i] = randomSetting(r, 0)
+				for k := range topKeys(layers[i]) {
+					keys[k] = struct{}{}
+				}
+				if tree, err = remerge(strategy, tree, keys, layers...); err != nil {
+					t.Fatal(err)
+				}
+				want, err := mergeTree(strategy, layers...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(tree, want) {
+					t.Fatalf("strategy %d round %d: remerge = %v, full merge = %v", strategy, round, tree, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package confremote_pilot
+
+import (
+	"context"
+	"github.com/fufuzion/confremote-pilot/logger"
+	"github.com/fufuzion/confremote-pilot/provider"
+	"time"
+)
+
+// MergeStrategy 决定多个source的配置如何合并到同一个视图
+type MergeStrategy int
+
+const (
+	MergeDeep    MergeStrategy = iota // 嵌套map逐层合并，同名叶子节点由后合并的source覆盖（viper默认行为）
+	MergeReplace                      // 同名顶层key由后合并的source整体覆盖
+)
+
+// HookDispatch 决定hook在哪个goroutine上执行
+type HookDispatch int
+
+const (
+	HookDispatchSync  HookDispatch = iota // 在Bridge的outbox上依次执行，保证按generation递增的顺序回调
+	HookDispatchAsync                     // 每次变更启动新的goroutine执行，不保证顺序
+)
+
+type providerFactory func(ctx context.Context, key string, cfg *Config) (provider.Provider, error)
+
+type bridgeOption struct {
+	hook            func(key string, msg map[string]any)
+	hookDispatch    HookDispatch
+	mergeStrategy   MergeStrategy
+	validators      []Validator
+	logger          logger.Logger
+	debounce        time.Duration
+	debounceMaxWait time.Duration
+	retry           RetryPolicy
+	cacheDir        string
+	history         int
+	applyPolicies   []prefixPolicy
+}
+
+type BridgeOption func(*bridgeOption)
+
+func WithHook(hook func(key string, msg map[string]any)) BridgeOption {
+	return func(o *bridgeOption) {
+		o.hook = hook
+	}
+}
+func WithHookDispatch(d HookDispatch) BridgeOption {
+	return func(o *bridgeOption) {
+		o.hookDispatch = d
+	}
+}
+func WithMergeStrategy(s MergeStrategy) BridgeOption {
+	return func(o *bridgeOption) {
+		o.mergeStrategy = s
+	}
+}
+
+// WithLogger 设置bridge及其provider的日志输出，默认使用slog.Default()，传入logger.Nop()关闭日志
+func WithLogger(l logger.Logger) BridgeOption {
+	return func(o *bridgeOption) {
+		o.logger = l
+	}
+}
@@ -0,0 +1,60 @@
+package confremote_pilot
+
+import (
+	"bytes"
+	"github.com/fufuzion/confremote-pilot/logger"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew_Independent(t *testing.T) {
+	b1, _ := newFakeBridge(t)
+	b2, _ := newFakeBridge(t)
+	if err := b1.RegisterSource("a", fakeConfig(map[string]any{"name": "b1"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := b2.RegisterSource("a", fakeConfig(map[string]any{"name": "b2"})); err != nil {
+		t.Fatal(err)
+	}
+	if got := b1.Get("name"); got != "b1" {
+		t.Errorf("b1 name = %v, want b1", got)
+	}
+	if got := b2.Get("name"); got != "b2" {
+		t.Errorf("b2 name = %v, want b2", got)
+	}
+}
+
+func TestWithHookDispatch(t *testing.T) {
+	done := make(chan string, 1)
+	b, f := newFakeBridge(t,
+		WithHookDispatch(HookDispatchAsync),
+		WithHook(func(key string, msg map[string]any) {
+			done <- key
+		}),
+	)
+	if err := b.RegisterSource("a", fakeConfig(nil)); err != nil {
+		t.Fatal(err)
+	}
+	f.get("a").push(map[string]any{"k": "v"})
+	select {
+	case key := <-done:
+		if key != "a" {
+			t.Errorf("hook key = %s, want a", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("hook not called")
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	b, _ := newFakeBridge(t, WithLogger(logger.NewSlog(slog.NewTextHandler(&buf, nil))))
+	if err := b.RegisterSource("a", fakeConfig(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, "source registered") || !strings.Contains(out, "source=a") {
+		t.Errorf("log output = %q", out)
+	}
+}
@@ -0,0 +1,37 @@
+package provider
+
+// Adapt 将只实现了Name/Load的Loader包装为Provider：
+// Close为空操作，Health由最近一次Load的结果推断，Revision始终为空
+func Adapt(l Loader) Provider {
+	if p, ok := l.(Provider); ok {
+		return p
+	}
+	return &loaderAdapter{Loader: l}
+}
+
+type loaderAdapter struct {
+	Loader
+	h health
+}
+
+func (a *loaderAdapter) Load() (map[string]interface{}, error) {
+	setting, err := a.Loader.Load()
+	if err != nil {
+		a.h.fail(err)
+		return nil, err
+	}
+	a.h.success()
+	return setting, nil
+}
+
+func (a *loaderAdapter) Close() error {
+	return nil
+}
+
+func (a *loaderAdapter) Health() HealthStatus {
+	return a.h.get()
+}
+
+func (a *loaderAdapter) Revision() string {
+	return ""
+}
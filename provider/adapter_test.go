@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+)
+
+type staticLoader struct {
+	data map[string]interface{}
+	err  error
+}
+
+func (l *staticLoader) Name() string { return "static" }
+func (l *staticLoader) Load() (map[string]interface{}, error) {
+	return l.data, l.err
+}
+
+func TestAdapt(t *testing.T) {
+	l := &staticLoader{data: map[string]interface{}{"k": "v"}}
+	p := Adapt(l)
+	if p.Name() != "static" || p.Revision() != "" || p.Close() != nil {
+		t.Fatal("unexpected adapter defaults")
+	}
+	if _, err := p.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if h := p.Health(); !h.Connected || h.LastRead.IsZero() {
+		t.Errorf("health after successful load = %+v", h)
+	}
+	l.err = errors.New("boom")
+	if _, err := p.Load(); err == nil {
+		t.Fatal("expected load error")
+	}
+	if h := p.Health(); h.LastError == nil {
+		t.Errorf("health after failed load = %+v", h)
+	}
+	if Adapt(p) != p {
+		t.Error("Adapt should return a Provider unchanged")
+	}
+}
@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUnknownProvider = errors.New("unknown provider type")
+
+// Op 出错时provider正在执行的操作
+type Op string
+
+const (
+	OpLoad      Op = "load"      // 读取远端配置
+	OpDecode    Op = "decode"    // 按ConfigType解码配置内容
+	OpWatch     Op = "watch"     // 监听配置变更
+	OpReconnect Op = "reconnect" // 断线后重连
+	OpValidate  Op = "validate"  // 合并后的配置未通过Validator
+)
+
+// SourceError 某个source在某个操作上的失败
+type SourceError struct {
+	Key      string // 注册source时使用的key
+	Provider CfgProviderType
+	Op       Op
+	Err      error
+}
+
+func (e *SourceError) Error() string {
+	return fmt.Sprintf("%s source %s %s: %v", e.Provider, e.Key, e.Op, e.Err)
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// opOf 返回err中SourceError的Op，不是SourceError时为空
+func opOf(err error) Op {
+	var serr *SourceError
+	if errors.As(err, &serr) {
+		return serr.Op
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package provider
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// health 记录provider最近一次读取的结果，供Health()返回
+type health struct {
+	mu     sync.RWMutex
+	status HealthStatus
+}
+
+func (h *health) success() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status.Connected = true
+	h.status.LastRead = time.Now()
+	h.status.LastError = nil
+}
+
+func (h *health) fail(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status.LastError = err
+}
+
+func (h *health) connect() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status.Connected = true
+}
+
+func (h *health) disconnect(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status.Connected = false
+	if err != nil {
+		h.status.LastError = err
+	}
+}
+
+func (h *health) get() HealthStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.status
+}
+
+func md5Hex(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// sleepCtx 等待d，ctx提前结束时返回false
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
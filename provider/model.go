@@ -1,5 +1,7 @@
 package provider
 
+import "time"
+
 type Source struct {
 	DataId string
 	Group  string
@@ -15,8 +17,15 @@ const (
 	CfgProviderConsul    CfgProviderType = "consul"
 	CfgProviderFirestore CfgProviderType = "firestore"
 	CfgProviderZookeeper CfgProviderType = "zookeeper"
+	CfgProviderOffline   CfgProviderType = "offline" // 从content或本地path读取，不连接远端
 )
 
 const (
 	SchemeTypeHttp = "http"
 )
+
+type HealthStatus struct {
+	Connected bool      // 与远端的连接是否可用
+	LastRead  time.Time // 最近一次成功读取的时间
+	LastError error     // 最近一次读取/监听失败的错误，成功读取后清空
+}
@@ -3,24 +3,39 @@ package provider
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/fufuzion/confremote-pilot/codec"
+	"github.com/fufuzion/confremote-pilot/logger"
 	"github.com/nacos-group/nacos-sdk-go/v2/clients"
 	"github.com/nacos-group/nacos-sdk-go/v2/clients/config_client"
+	"github.com/nacos-group/nacos-sdk-go/v2/common/nacos_error"
 	"github.com/nacos-group/nacos-sdk-go/v2/vo"
 	"github.com/thoas/go-funk"
+	"strings"
 	"sync"
+	"time"
 )
 
+// nacosProbeInterval 探测服务端连接状态的间隔
+var nacosProbeInterval = 10 * time.Second
+
 // https://nacos.io/docs/latest/manual/user/go-sdk/usage/
 // nacosProvider 包装nacos-group/nacos-sdk-go/v2
 type nacosProvider struct {
-	ctx    context.Context
-	tp     CfgProviderType
-	mu     *sync.RWMutex
-	data   map[string]map[string]interface{}
-	client config_client.IConfigClient
-	codec  codec.Codec
-	o      *option
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	tp        CfgProviderType
+	mu        *sync.RWMutex
+	data      map[string]map[string]interface{}
+	revisions map[string]string
+	contents  map[string]string // 各dataId的原始内容
+	client    config_client.IConfigClient
+	codec     codec.Codec
+	h         health
+	log       logger.Logger
+	o         *option
 }
 
 func checkParam(o *option) error {
@@ -41,30 +56,43 @@ func newNacosProvider(ctx context.Context, o *option) (Provider, error) {
 	if err != nil {
 		return nil, err
 	}
+	ctx, cancel := context.WithCancel(ctx)
 	p := &nacosProvider{
-		ctx:    ctx,
-		tp:     CfgProviderNacos,
-		mu:     &sync.RWMutex{},
-		codec:  codec.NewCodec(o.configType),
-		data:   make(map[string]map[string]interface{}),
-		client: client,
-		o:      o,
+		ctx:       ctx,
+		cancel:    cancel,
+		tp:        CfgProviderNacos,
+		mu:        &sync.RWMutex{},
+		codec:     codec.NewCodec(o.configType),
+		data:      make(map[string]map[string]interface{}),
+		revisions: make(map[string]string),
+		contents:  make(map[string]string),
+		client:    client,
+		log:       o.logger.With(logger.KeySource, o.customKey, logger.KeyProvider, CfgProviderNacos),
+		o:         o,
 	}
+	p.log.Info("nacos client created")
+	p.wg.Add(1)
+	go p.release()
 
 	for _, source := range o.sources {
-		subSetting, err := p.readRemote(source.DataId, source.Group)
+		content, subSetting, err := p.readRemote(source.DataId, source.Group)
 		if err != nil {
+			_ = p.Close()
 			return nil, err
 		}
-		p.mu.Lock()
-		p.data[p.dataKey(source.DataId, source.Group)] = subSetting
-		p.mu.Unlock()
+		revision := md5Hex([]byte(content))
+		p.store(source.DataId, source.Group, content, subSetting, revision)
+		p.log.Info("config loaded", logger.KeyDataId, source.DataId, logger.KeyGroup, source.Group, logger.KeyRevision, revision)
 
 		err = p.listen(source.DataId, source.Group)
 		if err != nil {
+			_ = p.Close()
 			return nil, err
 		}
 	}
+	p.h.success()
+	p.wg.Add(1)
+	go p.probe()
 	return p, nil
 }
 
@@ -72,32 +100,83 @@ func (p *nacosProvider) Name() string {
 	return p.tp.ToString()
 }
 
+// Load 按sources的顺序合并，靠后的dataId覆盖靠前的
 func (p *nacosProvider) Load() (map[string]interface{}, error) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 	ret := make(map[string]interface{})
-	for _, vm := range p.data {
-		for k, v := range vm {
+	for _, source := range p.o.sources {
+		for k, v := range p.data[p.dataKey(source.DataId, source.Group)] {
 			ret[k] = v
 		}
 	}
 	return ret, nil
 }
 
-func (p *nacosProvider) readRemote(dataId string, group string) (map[string]interface{}, error) {
+// LoadParts 按sources的顺序返回各dataId的原始内容、版本与解码后的配置
+func (p *nacosProvider) LoadParts() []Part {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	parts := make([]Part, 0, len(p.o.sources))
+	for _, source := range p.o.sources {
+		key := p.dataKey(source.DataId, source.Group)
+		parts = append(parts, Part{
+			Source:   source,
+			Revision: p.revisions[key],
+			Content:  p.contents[key],
+			Settings: p.data[key],
+		})
+	}
+	return parts
+}
+
+func (p *nacosProvider) Close() error {
+	p.closeOnce.Do(func() {
+		p.cancel()
+		p.wg.Wait()
+	})
+	return nil
+}
+
+func (p *nacosProvider) Health() HealthStatus {
+	return p.h.get()
+}
+
+// Revision 各dataId内容的MD5，多个dataId时按sources顺序以逗号拼接
+func (p *nacosProvider) Revision() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	revisions := make([]string, 0, len(p.o.sources))
+	for _, source := range p.o.sources {
+		revisions = append(revisions, p.revisions[p.dataKey(source.DataId, source.Group)])
+	}
+	return strings.Join(revisions, ",")
+}
+
+func (p *nacosProvider) readRemote(dataId string, group string) (string, map[string]interface{}, error) {
 	content, err := p.client.GetConfig(vo.ConfigParam{
 		DataId: dataId,
 		Group:  group,
 	})
 	if err != nil {
-		return nil, err
+		p.h.disconnect(err)
+		return "", nil, p.sourceError(OpLoad, err)
 	}
 	setting := make(map[string]interface{})
 	err = p.codec.Decode([]byte(content), &setting)
 	if err != nil {
-		return nil, err
+		return "", nil, p.sourceError(OpDecode, err)
 	}
-	return setting, nil
+	return content, setting, nil
+}
+
+func (p *nacosProvider) store(dataId, group, content string, setting map[string]interface{}, revision string) {
+	key := p.dataKey(dataId, group)
+	p.mu.Lock()
+	p.data[key] = setting
+	p.revisions[key] = revision
+	p.contents[key] = content
+	p.mu.Unlock()
 }
 func (p *nacosProvider) listen(dataId string, group string) error {
 	err := p.client.ListenConfig(vo.ConfigParam{
@@ -108,44 +187,94 @@ func (p *nacosProvider) listen(dataId string, group string) error {
 		},
 	})
 	if err != nil {
-		return err
-	}
-	go func() {
-		for {
-			select {
-			case <-p.ctx.Done():
-				_ = p.client.CancelListenConfig(vo.ConfigParam{
-					DataId: dataId,
-					Group:  group,
-				})
-				return
+		p.h.disconnect(err)
+		p.log.Error("listen config failed", logger.KeyDataId, dataId, logger.KeyGroup, group, logger.KeyError, err)
+		return p.sourceError(OpWatch, err)
+	}
+	p.log.Debug("listening config", logger.KeyDataId, dataId, logger.KeyGroup, group)
+	return nil
+}
+
+// probe 定期访问服务端更新Health().Connected。GetConfig失败时会回退到本地快照，client也不暴露连接状态，
+// 因此使用直接请求服务端的SearchConfig：服务端有响应（含鉴权失败等HTTP错误）即视为已连接
+func (p *nacosProvider) probe() {
+	defer p.wg.Done()
+	source := p.o.sources[0]
+	for sleepCtx(p.ctx, nacosProbeInterval) {
+		_, err := p.client.SearchConfig(vo.SearchConfigParam{
+			Search:   "accurate",
+			DataId:   source.DataId,
+			Group:    source.Group,
+			PageSize: 1,
+		})
+		var nerr *nacos_error.NacosError
+		connected := p.h.get().Connected
+		if err == nil || errors.As(err, &nerr) {
+			if !connected {
+				p.h.connect()
+				p.log.Info("nacos server reachable again")
 			}
+			continue
 		}
-	}()
-	return nil
+		if p.ctx.Err() != nil {
+			return
+		}
+		p.h.disconnect(err)
+		if connected {
+			p.report(p.sourceError(OpWatch, fmt.Errorf("nacos server unreachable: %w", err)))
+		}
+	}
+}
+
+// release ctx结束后取消全部监听并关闭client
+func (p *nacosProvider) release() {
+	defer p.wg.Done()
+	<-p.ctx.Done()
+	for _, source := range p.o.sources {
+		_ = p.client.CancelListenConfig(vo.ConfigParam{
+			DataId: source.DataId,
+			Group:  source.Group,
+		})
+	}
+	p.client.CloseClient()
+	p.log.Info("nacos client closed")
 }
 
 func (p *nacosProvider) onChange(group, dataId string, data string) {
 	setting := make(map[string]interface{})
 	err := p.codec.Decode([]byte(data), &setting)
 	if err != nil {
+		p.report(p.sourceError(OpDecode, fmt.Errorf("group %s dataId %s: %w", group, dataId, err)))
 		return
 	}
 	if funk.IsEmpty(setting) {
+		p.log.Warn("ignore empty config", logger.KeyDataId, dataId, logger.KeyGroup, group)
 		return
 	}
-	go p.notify(setting)
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	p.data[p.dataKey(dataId, group)] = setting
+	p.h.success()
+	revision := md5Hex([]byte(data))
+	p.log.Info("config changed", logger.KeyDataId, dataId, logger.KeyGroup, group, logger.KeyRevision, revision)
+	p.store(dataId, group, data, setting, revision)
+	p.notify(setting) // 先保存再通知，Bridge重新Load时才能读到新数据；Update只入队不会阻塞nacos的回调
 }
 
 func (p *nacosProvider) notify(change map[string]interface{}) {
-	if p.o.coordinator == nil {
+	if p.o.coordinator == nil || p.ctx.Err() != nil {
 		return
 	}
 	p.o.coordinator.Notify(p.o.customKey, change)
 }
+func (p *nacosProvider) sourceError(op Op, err error) *SourceError {
+	return &SourceError{Key: p.o.customKey, Provider: p.tp, Op: op, Err: err}
+}
+func (p *nacosProvider) report(err error) {
+	p.h.fail(err)
+	p.log.Error("provider error", logger.KeyOp, opOf(err), logger.KeyError, err)
+	if p.o.coordinator == nil || p.ctx.Err() != nil {
+		return
+	}
+	p.o.coordinator.NotifyError(p.o.customKey, err)
+}
 func (p *nacosProvider) dataKey(dataId string, group string) string {
 	return group + ":" + dataId
 }
@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"github.com/fufuzion/confremote-pilot/codec"
+	"github.com/fufuzion/confremote-pilot/logger"
+	"github.com/nacos-group/nacos-sdk-go/v2/clients/config_client"
+	"github.com/nacos-group/nacos-sdk-go/v2/common/nacos_error"
+	"github.com/nacos-group/nacos-sdk-go/v2/model"
+	"github.com/nacos-group/nacos-sdk-go/v2/vo"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// probeClient 只实现probe用到的SearchConfig
+type probeClient struct {
+	config_client.IConfigClient
+	mu  sync.Mutex
+	err error
+}
+
+func (c *probeClient) SearchConfig(vo.SearchConfigParam) (*model.ConfigPage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &model.ConfigPage{}, c.err
+}
+
+func (c *probeClient) setErr(err error) {
+	c.mu.Lock()
+	c.err = err
+	c.mu.Unlock()
+}
+
+func TestNacosProvider_Probe(t *testing.T) {
+	old := nacosProbeInterval
+	nacosProbeInterval = 5 * time.Millisecond
+	defer func() { nacosProbeInterval = old }()
+	client := &probeClient{}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &nacosProvider{
+		ctx:    ctx,
+		client: client,
+		log:    logger.Nop(),
+		o:      &option{sources: []*Source{{DataId: "d", Group: "g"}}},
+	}
+	p.h.success()
+	p.wg.Add(1)
+	go p.probe()
+	defer func() {
+		cancel()
+		p.wg.Wait()
+	}()
+
+	waitConnected := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for p.Health().Connected != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("connected = %v, want %v", !want, want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	client.setErr(errors.New("dial tcp: connection refused"))
+	waitConnected(false)
+	if p.Health().LastError == nil {
+		t.Error("LastError must be set when unreachable")
+	}
+	// 服务端返回HTTP错误说明连接正常
+	client.setErr(nacos_error.NewNacosError("403", "forbidden", nil))
+	waitConnected(true)
+}
+
+func TestNacosProvider_LoadParts(t *testing.T) {
+	a, b := &Source{DataId: "a", Group: "g"}, &Source{DataId: "b", Group: "g"}
+	p := &nacosProvider{
+		ctx:       context.Background(),
+		mu:        &sync.RWMutex{},
+		codec:     codec.NewCodec(codec.CfgFileTypeYaml),
+		data:      make(map[string]map[string]interface{}),
+		revisions: make(map[string]string),
+		contents:  make(map[string]string),
+		log:       logger.Nop(),
+		o:         &option{sources: []*Source{a, b}},
+	}
+	p.onChange("g", "a", "# a\nport: 1\nname: a\n")
+	p.onChange("g", "b", "port: 2\n")
+	parts := p.LoadParts()
+	if len(parts) != 2 || parts[0].Source != a || parts[1].Source != b {
+		t.Fatalf("parts = %+v, want a then b", parts)
+	}
+	if parts[0].Content != "# a\nport: 1\nname: a\n" || parts[0].Revision != md5Hex([]byte(parts[0].Content)) {
+		t.Errorf("part a = %+v, want the raw content and its MD5", parts[0])
+	}
+	loaded, _ := p.Load()
+	if merged := MergeParts(parts); !reflect.DeepEqual(merged, loaded) {
+		t.Errorf("merged parts = %v, want Load() = %v", merged, loaded)
+	}
+}
@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fufuzion/confremote-pilot/codec"
+	"github.com/fufuzion/confremote-pilot/logger"
+	"golang.org/x/exp/maps"
+	"os"
+	"strings"
+)
+
+// offlineProvider 从给定的内容或本地文件读取一次配置，不连接任何远端、不会产生变更通知，
+// 用于从快照包启动、本地复现以及不依赖nacos/zookeeper的集成测试。
+// properties中的parts（[]Part）给出多份原始内容时，逐份解码后按MergeParts合并
+type offlineProvider struct {
+	data     map[string]interface{}
+	parts    []Part
+	revision string
+	h        health
+}
+
+func newOfflineProvider(o *option) (Provider, error) {
+	if parts, ok := o.properties["parts"].([]Part); ok {
+		return newOfflinePartsProvider(o, parts)
+	}
+	var content []byte
+	switch v := o.properties["content"].(type) {
+	case string:
+		content = []byte(v)
+	case []byte:
+		content = v
+	case nil:
+		path, ok := o.properties["path"].(string)
+		if !ok {
+			return nil, errors.New("content or path is required")
+		}
+		var err error
+		if content, err = os.ReadFile(path); err != nil {
+			return nil, &SourceError{Key: o.customKey, Provider: CfgProviderOffline, Op: OpLoad, Err: err}
+		}
+	default:
+		return nil, fmt.Errorf("invalid content type: %T", v)
+	}
+	data := make(map[string]interface{})
+	if err := codec.NewCodec(o.configType).Decode(content, &data); err != nil {
+		return nil, &SourceError{Key: o.customKey, Provider: CfgProviderOffline, Op: OpDecode, Err: err}
+	}
+	revision, _ := o.properties["revision"].(string)
+	if revision == "" {
+		revision = md5Hex(content)
+	}
+	p := &offlineProvider{data: data, revision: revision}
+	p.h.success()
+	o.logger.Info("config loaded", logger.KeySource, o.customKey, logger.KeyProvider, CfgProviderOffline, logger.KeyRevision, revision)
+	return p, nil
+}
+
+func newOfflinePartsProvider(o *option, parts []Part) (Provider, error) {
+	c := codec.NewCodec(o.configType)
+	decoded := make([]Part, 0, len(parts))
+	revisions := make([]string, 0, len(parts))
+	for i, part := range parts {
+		settings := make(map[string]interface{})
+		if part.Content != "" { // 不存在的zookeeper节点内容为空
+			if err := c.Decode([]byte(part.Content), &settings); err != nil {
+				return nil, &SourceError{Key: o.customKey, Provider: CfgProviderOffline, Op: OpDecode, Err: fmt.Errorf("part %d: %w", i, err)}
+			}
+		}
+		part.Settings = settings
+		decoded = append(decoded, part)
+		revisions = append(revisions, part.Revision)
+	}
+	revision, _ := o.properties["revision"].(string)
+	if revision == "" {
+		revision = strings.Join(revisions, ",")
+	}
+	p := &offlineProvider{data: MergeParts(decoded), parts: decoded, revision: revision}
+	p.h.success()
+	o.logger.Info("config loaded", logger.KeySource, o.customKey, logger.KeyProvider, CfgProviderOffline, logger.KeyRevision, revision, "parts", len(decoded))
+	return p, nil
+}
+
+func (p *offlineProvider) Name() string {
+	return CfgProviderOffline.ToString()
+}
+func (p *offlineProvider) Load() (map[string]interface{}, error) {
+	ret := make(map[string]interface{})
+	maps.Copy(ret, p.data)
+	return ret, nil
+}
+
+// LoadParts 以parts创建时返回各份配置，否则为nil
+func (p *offlineProvider) LoadParts() []Part {
+	return p.parts
+}
+func (p *offlineProvider) Close() error {
+	return nil
+}
+func (p *offlineProvider) Health() HealthStatus {
+	return p.h.get()
+}
+
+// Revision properties中的revision，未设置时为内容的MD5
+func (p *offlineProvider) Revision() string {
+	return p.revision
+}
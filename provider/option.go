@@ -2,6 +2,7 @@ package provider
 
 import (
 	"github.com/fufuzion/confremote-pilot/codec"
+	"github.com/fufuzion/confremote-pilot/logger"
 	"github.com/fufuzion/confremote-pilot/mediator"
 )
 
@@ -11,6 +12,7 @@ type option struct {
 	properties  map[string]interface{} // 配置参数
 	sources     []*Source              // 每个source对应一个具体的远端配置文件
 	customKey   string
+	logger      logger.Logger
 }
 type Option func(*option)
 
@@ -40,3 +42,10 @@ func WithCustomKey(key string) Option {
 		o.customKey = key
 	}
 }
+
+// WithLogger 设置provider的日志输出，默认使用slog.Default()，传入logger.Nop()关闭日志
+func WithLogger(l logger.Logger) Option {
+	return func(o *option) {
+		o.logger = l
+	}
+}
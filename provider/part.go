@@ -0,0 +1,27 @@
+package provider
+
+// Part provider的一份原始配置，如nacos的一个dataId或zookeeper的节点
+type Part struct {
+	Source   *Source                // 该份配置对应的dataId/group，zookeeper为nil
+	Path     string                 // zookeeper的节点路径
+	Revision string                 // 该份配置的版本，nacos为内容的MD5，zookeeper为Stat.Version
+	Content  string                 // 远端返回的原始内容
+	Settings map[string]interface{} // Content解码后的配置
+}
+
+// PartLoader 可选接口，返回合并前的每一份配置，顺序与合并顺序一致，按MergeParts合并即为Load的结果；
+// 返回的Settings与provider共享，调用方不能修改
+type PartLoader interface {
+	LoadParts() []Part
+}
+
+// MergeParts 按顺序合并，靠后的顶层key覆盖靠前的
+func MergeParts(parts []Part) map[string]interface{} {
+	ret := make(map[string]interface{})
+	for _, part := range parts {
+		for k, v := range part.Settings {
+			ret[k] = v
+		}
+	}
+	return ret
+}
@@ -2,15 +2,26 @@ package provider
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"github.com/fufuzion/confremote-pilot/codec"
+	"github.com/fufuzion/confremote-pilot/logger"
 )
 
-type Provider interface {
+// Loader 只提供读取能力的最小provider，可通过Adapt包装为Provider
+type Loader interface {
 	Name() string
 	Load() (map[string]interface{}, error)
 }
 
+type Provider interface {
+	Loader
+	// Close 停止监听、释放client并等待内部goroutine退出，可重复调用
+	Close() error
+	Health() HealthStatus
+	// Revision 当前数据对应的远端版本，zookeeper为Stat.Version，nacos及viper系provider为内容的MD5
+	Revision() string
+}
+
 func NewProvider(ctx context.Context, tp CfgProviderType, opts ...Option) (Provider, error) {
 	o := &option{
 		configType: codec.CfgFileTypeYaml,
@@ -18,6 +29,9 @@ func NewProvider(ctx context.Context, tp CfgProviderType, opts ...Option) (Provi
 	for _, opt := range opts {
 		opt(o)
 	}
+	if o.logger == nil {
+		o.logger = logger.Default()
+	}
 	switch tp {
 	case CfgProviderNacos:
 		return newNacosProvider(ctx, o)
@@ -25,7 +39,9 @@ func NewProvider(ctx context.Context, tp CfgProviderType, opts ...Option) (Provi
 		return newViperBaseProvider(ctx, tp, o)
 	case CfgProviderZookeeper:
 		return newZookeeperProvider(ctx, o)
+	case CfgProviderOffline:
+		return newOfflineProvider(o)
 	default:
-		return nil, errors.New("unknown provider type")
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, tp)
 	}
 }
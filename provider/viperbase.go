@@ -3,17 +3,29 @@ package provider
 import (
 	"context"
 	"errors"
+	"github.com/fufuzion/confremote-pilot/codec"
+	"github.com/fufuzion/confremote-pilot/logger"
 	"github.com/spf13/viper"
 	_ "github.com/spf13/viper/remote"
 	"github.com/thoas/go-funk"
+	"sync"
 	"time"
 )
 
 type viperBaseProvider struct {
-	ctx context.Context
-	tp  CfgProviderType
-	vp  *viper.Viper
-	o   *option
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	tp        CfgProviderType
+	vp        *viper.Viper
+	codec     codec.Codec
+	mu        *sync.RWMutex
+	data      map[string]interface{}
+	revision  string
+	h         health
+	log       logger.Logger
+	o         *option
 }
 
 func newViperBaseProvider(ctx context.Context, tp CfgProviderType, o *option) (Provider, error) {
@@ -36,12 +48,20 @@ func newViperBaseProvider(ctx context.Context, tp CfgProviderType, o *option) (P
 	if err := vp.ReadRemoteConfig(); err != nil {
 		return nil, err
 	}
+	ctx, cancel := context.WithCancel(ctx)
 	provider := &viperBaseProvider{
-		ctx: ctx,
-		tp:  tp,
-		vp:  vp,
-		o:   o,
+		ctx:    ctx,
+		cancel: cancel,
+		tp:     tp,
+		vp:     vp,
+		codec:  codec.NewCodec(o.configType),
+		mu:     &sync.RWMutex{},
+		log:    o.logger.With(logger.KeySource, o.customKey, logger.KeyProvider, tp, logger.KeyPath, path),
+		o:      o,
 	}
+	provider.refresh()
+	provider.h.success()
+	provider.log.Info("config loaded", logger.KeyRevision, provider.revision)
 	provider.listen()
 	return provider, nil
 }
@@ -50,11 +70,53 @@ func (p *viperBaseProvider) Name() string {
 }
 
 func (p *viperBaseProvider) Load() (map[string]interface{}, error) {
-	return p.vp.AllSettings(), nil
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.data, nil
+}
+
+func (p *viperBaseProvider) Close() error {
+	p.closeOnce.Do(func() {
+		p.cancel()
+		p.wg.Wait()
+	})
+	return nil
+}
+
+func (p *viperBaseProvider) Health() HealthStatus {
+	return p.h.get()
+}
+
+// Revision viper的remote接口不暴露etcd mod revision等元数据，使用重新编码后内容的MD5
+func (p *viperBaseProvider) Revision() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.revision
+}
+
+// refresh 从viper取出最新配置，内容发生变化时返回true；只在监听goroutine或构造时调用
+func (p *viperBaseProvider) refresh() bool {
+	settings := p.vp.AllSettings()
+	content, err := p.codec.Encode(settings)
+	if err != nil {
+		p.report(p.sourceError(OpDecode, err))
+		return false
+	}
+	revision := md5Hex(content)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if revision == p.revision {
+		return false
+	}
+	p.data = settings
+	p.revision = revision
+	return true
 }
 
 func (p *viperBaseProvider) listen() {
+	p.wg.Add(1)
 	go func() {
+		defer p.wg.Done()
 		backoff := time.Second
 		for {
 			select {
@@ -62,24 +124,40 @@ func (p *viperBaseProvider) listen() {
 				return
 			default:
 				if err := p.vp.WatchRemoteConfig(); err != nil {
-					time.Sleep(backoff)
+					p.h.disconnect(err)
+					p.report(p.sourceError(OpWatch, err))
+					if !sleepCtx(p.ctx, backoff) {
+						return
+					}
 					if backoff < 30*time.Second {
 						backoff *= 2
 					}
 					continue
 				}
 				backoff = time.Second
-				settings := func() map[string]interface{} {
-					return p.vp.AllSettings()
-				}()
-				p.notify(settings)
+				p.h.success()
+				if p.refresh() {
+					p.log.Info("config changed", logger.KeyRevision, p.revision)
+					p.notify(p.data)
+				}
 			}
 		}
 	}()
 }
 func (p *viperBaseProvider) notify(change map[string]interface{}) {
-	if p.o.coordinator == nil {
+	if p.o.coordinator == nil || p.ctx.Err() != nil {
 		return
 	}
 	p.o.coordinator.Notify(p.o.customKey, change)
 }
+func (p *viperBaseProvider) sourceError(op Op, err error) *SourceError {
+	return &SourceError{Key: p.o.customKey, Provider: p.tp, Op: op, Err: err}
+}
+func (p *viperBaseProvider) report(err error) {
+	p.h.fail(err)
+	p.log.Error("provider error", logger.KeyOp, opOf(err), logger.KeyError, err)
+	if p.o.coordinator == nil || p.ctx.Err() != nil {
+		return
+	}
+	p.o.coordinator.NotifyError(p.o.customKey, err)
+}
@@ -5,9 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"github.com/fufuzion/confremote-pilot/codec"
+	"github.com/fufuzion/confremote-pilot/logger"
 	"github.com/go-zookeeper/zk"
 	"github.com/thoas/go-funk"
 	"golang.org/x/exp/maps"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -16,6 +18,9 @@ import (
 
 type zookeeperProvider struct {
 	ctx          context.Context
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+	closeOnce    sync.Once
 	tp           CfgProviderType
 	conn         *zk.Conn
 	stateCh      <-chan zk.Event
@@ -23,6 +28,10 @@ type zookeeperProvider struct {
 	codec        codec.Codec
 	mu           *sync.RWMutex
 	data         map[string]interface{}
+	content      string // 节点的原始内容
+	revision     string
+	h            health
+	log          logger.Logger
 	watchPath    string
 	listenCancel context.CancelFunc
 	reconnecting uint32
@@ -48,13 +57,17 @@ func newZookeeperProvider(ctx context.Context, o *option) (Provider, error) {
 			return nil, err
 		}
 	}
+	log := o.logger.With(logger.KeySource, o.customKey, logger.KeyProvider, CfgProviderZookeeper, logger.KeyPath, path)
 	servers := strings.Split(endpoint, ",")
-	conn, eventCh, err := zk.Connect(servers, timeout)
+	log.Info("zookeeper connecting", "servers", servers)
+	conn, eventCh, err := zk.Connect(servers, timeout, zk.WithLogger(zkLogger{log}))
 	if err != nil {
 		return nil, fmt.Errorf("connect zookeeper failed: %w", err)
 	}
+	ctx, cancel := context.WithCancel(ctx)
 	provider := &zookeeperProvider{
 		ctx:       ctx,
+		cancel:    cancel,
 		tp:        CfgProviderZookeeper,
 		conn:      conn,
 		stateCh:   eventCh,
@@ -62,15 +75,18 @@ func newZookeeperProvider(ctx context.Context, o *option) (Provider, error) {
 		o:         o,
 		data:      make(map[string]interface{}),
 		mu:        &sync.RWMutex{},
+		log:       log,
 		watchPath: path,
 	}
-	setting, err := provider.readRemote(path)
+	content, setting, revision, err := provider.readRemote(path)
 	if err != nil {
+		cancel()
+		conn.Close()
 		return nil, err
 	}
-	provider.mu.Lock()
-	provider.data = setting
-	provider.mu.Unlock()
+	provider.store(content, setting, revision)
+	provider.h.success()
+	provider.log.Info("config loaded", logger.KeyRevision, revision)
 
 	provider.listen(path)
 	provider.watchConnection()
@@ -108,29 +124,62 @@ func (p *zookeeperProvider) Load() (map[string]interface{}, error) {
 	maps.Copy(ret, p.data)
 	return ret, nil
 }
-func (p *zookeeperProvider) readRemote(path string) (map[string]interface{}, error) {
-	content, _, err := p.conn.Get(path)
+func (p *zookeeperProvider) Close() error {
+	p.closeOnce.Do(func() {
+		p.cancel()
+		p.wg.Wait()
+	})
+	return nil
+}
+func (p *zookeeperProvider) Health() HealthStatus {
+	return p.h.get()
+}
+
+// Revision 节点的Stat.Version，节点不存在时为空
+func (p *zookeeperProvider) Revision() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.revision
+}
+
+// LoadParts 节点的原始内容与版本，作为唯一的一份配置
+func (p *zookeeperProvider) LoadParts() []Part {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return []Part{{Path: p.watchPath, Revision: p.revision, Content: p.content, Settings: p.data}}
+}
+func (p *zookeeperProvider) readRemote(path string) (string, map[string]interface{}, string, error) {
+	content, stat, err := p.conn.Get(path)
 	switch {
 	case errors.Is(err, zk.ErrNoNode): // 支持空节点启动后再写入数据
-		return make(map[string]interface{}), nil
+		return "", make(map[string]interface{}), "", nil
 	case err != nil:
-		return nil, err
+		return "", nil, "", p.sourceError(OpLoad, err)
 	}
 	setting := make(map[string]interface{})
 	err = p.codec.Decode(content, &setting)
 	if err != nil {
-		return nil, err
+		return "", nil, "", p.sourceError(OpDecode, err)
 	}
-	return setting, nil
+	return string(content), setting, strconv.Itoa(int(stat.Version)), nil
+}
+func (p *zookeeperProvider) store(content string, setting map[string]interface{}, revision string) {
+	p.mu.Lock()
+	p.data = setting
+	p.content = content
+	p.revision = revision
+	p.mu.Unlock()
 }
 func (p *zookeeperProvider) onChange(path string) {
-	settings, err := p.readRemote(path)
-	if err == nil {
-		p.mu.Lock()
-		p.data = settings
-		p.mu.Unlock()
-		p.notify(settings)
+	content, settings, revision, err := p.readRemote(path)
+	if err != nil {
+		p.report(err)
+		return
 	}
+	p.h.success()
+	p.store(content, settings, revision)
+	p.log.Info("config changed", logger.KeyRevision, revision)
+	p.notify(settings)
 }
 func (p *zookeeperProvider) listen(path string) {
 	if p.listenCancel != nil {
@@ -138,16 +187,21 @@ func (p *zookeeperProvider) listen(path string) {
 	}
 	ctx, cancel := context.WithCancel(p.ctx)
 	p.listenCancel = cancel
+	conn := p.conn
+	p.wg.Add(1)
 	go func() {
+		defer p.wg.Done()
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			default:
-				_, _, ch, err := p.conn.GetW(path)
+				_, _, ch, err := conn.GetW(path)
 				if err != nil {
-					fmt.Println("zookeeperProvider.listen.GetW failed: ", err)
-					time.Sleep(2 * time.Second)
+					p.report(p.sourceError(OpWatch, err))
+					if !sleepCtx(ctx, 2*time.Second) {
+						return
+					}
 					continue
 				}
 				select {
@@ -155,7 +209,9 @@ func (p *zookeeperProvider) listen(path string) {
 					return
 				case ev, ok := <-ch:
 					if !ok {
-						time.Sleep(2 * time.Second)
+						if !sleepCtx(ctx, 2*time.Second) {
+							return
+						}
 						continue
 					}
 					switch ev.Type {
@@ -169,13 +225,18 @@ func (p *zookeeperProvider) listen(path string) {
 }
 
 func (p *zookeeperProvider) notify(change map[string]interface{}) {
-	if p.o.coordinator == nil {
+	if p.o.coordinator == nil || p.ctx.Err() != nil {
 		return
 	}
 	p.o.coordinator.Notify(p.o.customKey, change)
 }
 func (p *zookeeperProvider) watchConnection() {
+	p.wg.Add(1)
 	go func() {
+		defer p.wg.Done()
+		defer func() {
+			p.conn.Close()
+		}()
 		for {
 			select {
 			case <-p.ctx.Done():
@@ -187,11 +248,21 @@ func (p *zookeeperProvider) watchConnection() {
 					return
 				case ev, ok := <-stateCh:
 					if !ok {
-						time.Sleep(time.Second)
+						if !sleepCtx(p.ctx, time.Second) {
+							return
+						}
 						continue
 					}
-					if ev.State == zk.StateExpired || ev.State == zk.StateDisconnected || ev.State == zk.StateUnknown {
-						time.Sleep(time.Second)
+					switch ev.State {
+					case zk.StateHasSession:
+						p.h.connect()
+						p.log.Info("zookeeper session established")
+					case zk.StateExpired, zk.StateDisconnected, zk.StateUnknown:
+						p.h.disconnect(ev.Err)
+						p.log.Warn("zookeeper connection lost, reconnecting", "state", ev.State.String(), logger.KeyError, ev.Err)
+						if !sleepCtx(p.ctx, time.Second) {
+							return
+						}
 						p.reconnect()
 					}
 				}
@@ -210,12 +281,11 @@ func (p *zookeeperProvider) reconnect() {
 	servers := strings.Split(endpoint, ",")
 	timeout := 5 * time.Second
 	if timeoutVal, ok := p.o.properties["timeout"]; ok {
-		if timeoutInt, ok := timeoutVal.(int); ok {
-			timeout = time.Duration(timeoutInt) * time.Second
-		}
+		timeout, _ = parseTimeout(timeoutVal) // 构造时已校验过
 	}
-	conn, eventCh, err := zk.Connect(servers, timeout)
+	conn, eventCh, err := zk.Connect(servers, timeout, zk.WithLogger(zkLogger{p.log}))
 	if err != nil {
+		p.report(p.sourceError(OpReconnect, err))
 		return
 	}
 	oldConn := p.conn
@@ -223,11 +293,34 @@ func (p *zookeeperProvider) reconnect() {
 	p.conn = conn
 	p.stateCh = eventCh
 
-	if settings, err := p.readRemote(p.watchPath); err == nil {
-		p.mu.Lock()
-		p.data = settings
-		p.mu.Unlock()
+	p.log.Info("zookeeper reconnected")
+	if content, settings, revision, err := p.readRemote(p.watchPath); err == nil {
+		p.h.success()
+		p.log.Info("config loaded", logger.KeyRevision, revision)
+		p.store(content, settings, revision)
+	} else {
+		p.report(err)
 	}
 
 	p.listen(p.watchPath)
 }
+func (p *zookeeperProvider) sourceError(op Op, err error) *SourceError {
+	return &SourceError{Key: p.o.customKey, Provider: p.tp, Op: op, Err: err}
+}
+func (p *zookeeperProvider) report(err error) {
+	p.h.fail(err)
+	p.log.Error("provider error", logger.KeyOp, opOf(err), logger.KeyError, err)
+	if p.o.coordinator == nil || p.ctx.Err() != nil {
+		return
+	}
+	p.o.coordinator.NotifyError(p.o.customKey, err)
+}
+
+// zkLogger 将go-zookeeper内部的日志转到logger.Logger
+type zkLogger struct {
+	log logger.Logger
+}
+
+func (l zkLogger) Printf(format string, args ...interface{}) {
+	l.log.Debug(fmt.Sprintf(format, args...))
+}
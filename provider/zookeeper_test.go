@@ -0,0 +1,21 @@
+package provider
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestZookeeperProvider_LoadParts(t *testing.T) {
+	p := &zookeeperProvider{mu: &sync.RWMutex{}, watchPath: "/conf/app"}
+	p.store("# app\nport: 1\n", map[string]interface{}{"port": 1}, "3")
+	parts := p.LoadParts()
+	if len(parts) != 1 {
+		t.Fatalf("parts = %+v, want the node only", parts)
+	}
+	if part := parts[0]; part.Source != nil || part.Path != "/conf/app" || part.Revision != "3" || part.Content != "# app\nport: 1\n" {
+		t.Errorf("part = %+v, want the raw node content at version 3", part)
+	}
+	if loaded, _ := p.Load(); loaded["port"] != MergeParts(parts)["port"] {
+		t.Errorf("merged parts differ from Load() = %v", loaded)
+	}
+}
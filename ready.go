@@ -0,0 +1,186 @@
+package confremote_pilot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fufuzion/confremote-pilot/logger"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errPendingCancelled = errors.New("pending registration cancelled")
+
+// pendingSource 一个正在后台注册的source
+type pendingSource struct {
+	key      string
+	optional bool
+	done     chan struct{} // 注册成功或放弃重试时关闭
+
+	mu      sync.Mutex
+	lastErr error // 最近一次注册失败的原因
+	err     error // 放弃重试的原因，注册成功时为nil
+}
+
+func (p *pendingSource) fail(err error) {
+	p.mu.Lock()
+	p.lastErr = err
+	p.mu.Unlock()
+}
+
+func (p *pendingSource) finish(err error) {
+	p.mu.Lock()
+	p.err = err
+	p.mu.Unlock()
+	close(p.done)
+}
+
+func (p *pendingSource) result() (err, lastErr error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err, p.lastErr
+}
+
+// RegisterSourceAsync 在后台注册source，失败时按重试策略重试直到成功、次数用完、被注销或bridge关闭，
+// 成功后合并到视图；开启WithCacheDir且有缓存时，首次失败即以缓存注册并在后台重连（见EventStale）；只在key已存在或bridge已关闭时立即返回错误。配合WaitReady等待其就绪
+func (b *Bridge) RegisterSourceAsync(key string, cfg *Config) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBridgeClosed
+	}
+	if b.exists(key) {
+		b.mu.Unlock()
+		return fmt.Errorf("%w, use ReplaceSource: %s", ErrSourceExists, key)
+	}
+	p := &pendingSource{key: key, optional: cfg.Optional, done: make(chan struct{})}
+	b.pending[key] = p
+	b.mu.Unlock()
+	go b.registerLoop(p, cfg)
+	return nil
+}
+
+func (b *Bridge) registerLoop(p *pendingSource, cfg *Config) {
+	policy := b.retryPolicy(cfg)
+	for attempt := 1; ; attempt++ {
+		s, err := b.createSource(p.key, cfg)
+		if err == nil {
+			err = b.add(s, p)
+		} else if stale := b.staleSource(p.key, cfg, err); stale != nil {
+			err = b.registerStale(stale, p)
+		}
+		switch {
+		case err == nil, errors.Is(err, errPendingCancelled), errors.Is(err, ErrBridgeClosed):
+			return
+		case !retryable(err), policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts:
+			b.giveUp(p, err)
+			return
+		}
+		p.fail(err)
+		backoff := policy.backoff(attempt)
+		b.o.logger.Warn("register source failed, retrying", logger.KeySource, p.key, logger.KeyProvider, cfg.Provider, "attempt", attempt, "backoff", backoff, logger.KeyError, err)
+		var serr *SourceError
+		if errors.As(err, &serr) {
+			b.reportError(serr)
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-p.done:
+			timer.Stop()
+			return
+		case <-b.done:
+			timer.Stop()
+			return
+		case <-b.ctx.Done():
+			timer.Stop()
+			b.giveUp(p, b.ctx.Err())
+			return
+		}
+	}
+}
+
+// giveUp 放弃后台注册，WaitReady对该key返回err
+func (b *Bridge) giveUp(p *pendingSource, err error) {
+	b.mu.Lock()
+	if b.pending[p.key] != p {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.pending, p.key)
+	b.mu.Unlock()
+	b.o.logger.Error("register source abandoned", logger.KeySource, p.key, logger.KeyError, err)
+	p.finish(err)
+}
+
+// Ready 没有仍在后台注册的必需source，可选的source不影响就绪
+func (b *Bridge) Ready() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, p := range b.pending {
+		if !p.optional {
+			return false
+		}
+	}
+	return true
+}
+
+// WaitReady 等待keys对应的source注册完成，keys为空时等待当前全部后台注册的必需source；
+// 未知的key返回ErrSourceNotFound，放弃重试的source返回其原因，ctx结束时返回仍未就绪的key及其最近一次失败原因
+func (b *Bridge) WaitReady(ctx context.Context, keys ...string) error {
+	b.mu.RLock()
+	var waits []*pendingSource
+	if len(keys) == 0 {
+		for _, p := range b.pending {
+			if !p.optional {
+				waits = append(waits, p)
+			}
+		}
+	}
+	for _, key := range keys {
+		if p, ok := b.pending[key]; ok {
+			waits = append(waits, p)
+			continue
+		}
+		if _, ok := b.pvm[key]; !ok {
+			b.mu.RUnlock()
+			return fmt.Errorf("%w: %s", ErrSourceNotFound, key)
+		}
+	}
+	b.mu.RUnlock()
+	sort.Slice(waits, func(i, j int) bool {
+		return waits[i].key < waits[j].key
+	})
+
+	var errs []error
+	for i, p := range waits {
+		select {
+		case <-p.done:
+			if err, _ := p.result(); err != nil {
+				errs = append(errs, fmt.Errorf("source %s: %w", p.key, err))
+			}
+		case <-ctx.Done():
+			return notReady(ctx.Err(), waits[i:])
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func notReady(cause error, waits []*pendingSource) error {
+	var msgs []string
+	for _, p := range waits {
+		select {
+		case <-p.done:
+			continue
+		default:
+		}
+		msg := p.key
+		if _, lastErr := p.result(); lastErr != nil {
+			msg += " (" + lastErr.Error() + ")"
+		}
+		msgs = append(msgs, msg)
+	}
+	return fmt.Errorf("sources not ready: %s: %w", strings.Join(msgs, ", "), cause)
+}
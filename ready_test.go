@@ -0,0 +1,113 @@
+package confremote_pilot
+
+import (
+	"context"
+	"errors"
+	"github.com/fufuzion/confremote-pilot/provider"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBridge_RegisterSourceAsync(t *testing.T) {
+	b, _ := newFakeBridge(t, WithRetryPolicy(RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}))
+	create := b.factory
+	var attempts int32
+	b.factory = func(ctx context.Context, key string, cfg *Config) (provider.Provider, error) {
+		if atomic.AddInt32(&attempts, 1) <= 2 {
+			return nil, errors.New("unavailable")
+		}
+		return create(ctx, key, cfg)
+	}
+	var reported int32
+	b.OnError(func(SourceError) {
+		atomic.AddInt32(&reported, 1)
+	})
+	if err := b.RegisterSourceAsync("a", fakeConfig(map[string]any{"name": "a"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RegisterSource("a", fakeConfig(nil)); !errors.Is(err, ErrSourceExists) {
+		t.Errorf("RegisterSource of pending key err = %v, want ErrSourceExists", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := b.WaitReady(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if !b.Ready() || b.Get("name") != "a" {
+		t.Errorf("ready = %v, name = %v", b.Ready(), b.Get("name"))
+	}
+	if got := atomic.LoadInt32(&reported); got != 2 {
+		t.Errorf("reported errors = %d, want 2", got)
+	}
+	if err := b.WaitReady(ctx, "missing"); !errors.Is(err, ErrSourceNotFound) {
+		t.Errorf("WaitReady(missing) err = %v, want ErrSourceNotFound", err)
+	}
+}
+
+func TestBridge_WaitReadyTimeout(t *testing.T) {
+	b, _ := newFakeBridge(t, WithRetryPolicy(RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 10 * time.Millisecond}))
+	bad := &Config{Provider: fakeProviderType, Properties: map[string]any{"err": "unavailable"}}
+	if err := b.RegisterSourceAsync("bad", bad); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RegisterSource("good", fakeConfig(map[string]any{"x": 1})); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := b.WaitReady(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || b.Ready() {
+		t.Fatalf("WaitReady err = %v, ready = %v", err, b.Ready())
+	}
+	if err = b.WaitReady(context.Background(), "good"); err != nil {
+		t.Errorf("registered source must be ready: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- b.WaitReady(context.Background(), "bad")
+	}()
+	if err = b.UnregisterSource("bad"); err != nil {
+		t.Fatal(err)
+	}
+	if err = <-done; err == nil {
+		t.Error("WaitReady must fail for a cancelled registration")
+	}
+	if !b.Ready() {
+		t.Error("bridge must be ready after the pending source is unregistered")
+	}
+}
+
+func TestBridge_RegisterSourceAsyncClose(t *testing.T) {
+	b, _ := newFakeBridge(t, WithRetryPolicy(RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 10 * time.Millisecond}))
+	bad := &Config{Provider: fakeProviderType, Properties: map[string]any{"err": "unavailable"}}
+	if err := b.RegisterSourceAsync("bad", bad); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.WaitReady(context.Background(), "bad"); err == nil {
+		t.Error("WaitReady after Close must not succeed")
+	}
+	if err := b.RegisterSourceAsync("c", fakeConfig(nil)); !errors.Is(err, ErrBridgeClosed) {
+		t.Errorf("err = %v, want ErrBridgeClosed", err)
+	}
+}
+
+func TestBridge_RegisterSourceBatchPending(t *testing.T) {
+	b, _ := newFakeBridge(t, WithRetryPolicy(fastRetry))
+	flakyFactory(b, "a", 1000)
+	if err := b.RegisterSourceAsync("a", fakeConfig(nil)); err != nil {
+		t.Fatal(err)
+	}
+	err := b.RegisterSourceBatch(map[string]*Config{"a": fakeConfig(nil), "b": fakeConfig(nil)})
+	var berr *BatchError
+	if !errors.As(err, &berr) || !errors.Is(berr.Failed["a"], ErrSourceExists) {
+		t.Fatalf("err = %v, want ErrSourceExists for the pending key", err)
+	}
+	if len(b.Layers()) != 0 {
+		t.Errorf("layers = %v, batch must not register", b.Layers())
+	}
+}
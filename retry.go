@@ -0,0 +1,103 @@
+package confremote_pilot
+
+import (
+	"context"
+	"errors"
+	"github.com/fufuzion/confremote-pilot/logger"
+	"github.com/fufuzion/confremote-pilot/provider"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy 创建source失败后的重试策略
+type RetryPolicy struct {
+	MaxAttempts    int           `json:"max_attempts"`    // 最多尝试次数（含第一次），<=0时同步注册只尝试一次、后台注册不限次数
+	InitialBackoff time.Duration `json:"initial_backoff"` // 第一次重试前的等待，之后每次翻倍
+	MaxBackoff     time.Duration `json:"max_backoff"`
+	Jitter         float64       `json:"jitter"` // 等待时间在[1-Jitter, 1+Jitter]倍之间随机，取值0~1
+}
+
+var defaultRetryPolicy = RetryPolicy{
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+	Jitter:         0.2,
+}
+
+// WithRetryPolicy 全部source默认的重试策略，未设置的backoff使用默认值1s、30s
+func WithRetryPolicy(p RetryPolicy) BridgeOption {
+	return func(o *bridgeOption) {
+		o.retry = p.withDefaults(defaultRetryPolicy)
+	}
+}
+
+func (p RetryPolicy) withDefaults(def RetryPolicy) RetryPolicy {
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = def.InitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = def.MaxBackoff
+	}
+	p.Jitter = min(max(p.Jitter, 0), 1)
+	return p
+}
+
+// backoff 第retry次重试前的等待，retry从1开始
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < retry && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxBackoff)
+	if p.Jitter > 0 {
+		d = time.Duration(float64(d) * (1 + p.Jitter*(2*rand.Float64()-1)))
+	}
+	return d
+}
+
+// retryPolicy source的重试策略，Config.Retry中未设置的backoff沿用bridge的设置
+func (b *Bridge) retryPolicy(cfg *Config) RetryPolicy {
+	if cfg.Retry == nil {
+		return b.o.retry
+	}
+	return cfg.Retry.withDefaults(b.o.retry)
+}
+
+// retryable 重试无法恢复的错误返回false
+func retryable(err error) bool {
+	return !errors.Is(err, provider.ErrUnknownProvider) &&
+		!errors.Is(err, ErrBridgeClosed) &&
+		!errors.Is(err, errPendingCancelled)
+}
+
+// sleep 等待d，ctx结束或bridge关闭时返回false
+func (b *Bridge) sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+	case <-b.ctx.Done():
+	case <-b.done:
+	}
+	return false
+}
+
+// createWithRetry 最多尝试attempts次创建source，attempts<=0时只尝试一次；ctx结束时返回最后一次的错误
+func (b *Bridge) createWithRetry(ctx context.Context, key string, cfg *Config, attempts int) (*source, error) {
+	policy := b.retryPolicy(cfg)
+	for attempt := 1; ; attempt++ {
+		s, err := b.createSource(key, cfg)
+		if err == nil {
+			return s, nil
+		}
+		if attempt >= attempts || !retryable(err) {
+			return nil, err
+		}
+		backoff := policy.backoff(attempt)
+		b.o.logger.Warn("create source failed, retrying", logger.KeySource, key, logger.KeyProvider, cfg.Provider, "attempt", attempt, "backoff", backoff, logger.KeyError, err)
+		if !b.sleep(ctx, backoff) {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package confremote_pilot
+
+import (
+	"context"
+	"errors"
+	"github.com/fufuzion/confremote-pilot/provider"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	want := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w*time.Millisecond {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w*time.Millisecond)
+		}
+	}
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.backoff(2); got < 100*time.Millisecond || got > 300*time.Millisecond {
+			t.Fatalf("backoff with jitter = %v, want within [100ms, 300ms]", got)
+		}
+	}
+}
+
+// flakyFactory 让key的前n次创建失败
+func flakyFactory(b *Bridge, key string, n int32) *int32 {
+	create := b.factory
+	var attempts int32
+	b.factory = func(ctx context.Context, k string, cfg *Config) (provider.Provider, error) {
+		if k == key && atomic.AddInt32(&attempts, 1) <= n {
+			return nil, errors.New("unavailable")
+		}
+		return create(ctx, k, cfg)
+	}
+	return &attempts
+}
+
+var fastRetry = RetryPolicy{InitialBackoff: 5 * time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+func TestBridge_RegisterSourceRetry(t *testing.T) {
+	b, _ := newFakeBridge(t, WithRetryPolicy(fastRetry))
+	attempts := flakyFactory(b, "a", 2)
+	if err := b.RegisterSource("a", fakeConfig(nil)); err == nil {
+		t.Fatal("required source without a retry budget must fail on the first error")
+	}
+	cfg := fakeConfig(map[string]any{"a": 1})
+	cfg.Retry = &RetryPolicy{MaxAttempts: 3}
+	if err := b.RegisterSource("a", cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(attempts); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+
+	attempts = flakyFactory(b, "b", 10)
+	cfg = fakeConfig(nil)
+	cfg.Retry = &RetryPolicy{MaxAttempts: 4}
+	if err := b.RegisterSource("b", cfg); err == nil {
+		t.Error("required source must fail once its retry budget is exhausted")
+	}
+	if got := atomic.LoadInt32(attempts); got != 4 {
+		t.Errorf("attempts = %d, want 4", got)
+	}
+}
+
+func TestBridge_OptionalSource(t *testing.T) {
+	b, _ := newFakeBridge(t, WithRetryPolicy(fastRetry))
+	flakyFactory(b, "opt", 3)
+	cfg := fakeConfig(map[string]any{"opt": true})
+	cfg.Optional = true
+	if err := b.RegisterSource("opt", cfg); err != nil {
+		t.Fatalf("optional source must not fail registration: %v", err)
+	}
+	if !b.Ready() {
+		t.Error("a pending optional source must not affect Ready")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := b.WaitReady(ctx, "opt"); err != nil {
+		t.Fatal(err)
+	}
+	b.flush()
+	if b.Get("opt") != true {
+		t.Error("optional source not merged after it came up")
+	}
+
+	flakyFactory(b, "gone", 100)
+	cfg = fakeConfig(nil)
+	cfg.Optional = true
+	cfg.Retry = &RetryPolicy{MaxAttempts: 2}
+	if err := b.RegisterSource("gone", cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.WaitReady(ctx, "gone"); err == nil {
+		t.Error("WaitReady must report a source whose retries are exhausted")
+	}
+}
+
+func TestBridge_RegisterSourceBatchOptional(t *testing.T) {
+	b, _ := newFakeBridge(t, WithRetryPolicy(fastRetry))
+	flakyFactory(b, "opt", 2)
+	opt := fakeConfig(map[string]any{"opt": true})
+	opt.Optional = true
+	err := b.RegisterSourceBatch(map[string]*Config{
+		"a":   fakeConfig(map[string]any{"a": true}),
+		"opt": opt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Get("a") != true {
+		t.Error("required source not merged")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err = b.WaitReady(ctx, "opt"); err != nil {
+		t.Fatal(err)
+	}
+}
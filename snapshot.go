@@ -0,0 +1,138 @@
+package confremote_pilot
+
+import (
+	"github.com/spf13/viper"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Snapshot 合并视图的不可变快照，每次更新只构建一次：
+// 嵌套的配置树以及 路径->值 的索引（含中间节点），读取时无锁、无分配。
+// Tree、Get等返回的map/slice与快照共享，不得修改
+type Snapshot struct {
+	tree   map[string]any
+	index  map[string]any // 全部节点，中间节点对应其子树
+	leaves []string       // 叶子节点路径，已排序
+	gen    uint64
+	vpOnce sync.Once
+	vp     *viper.Viper // 兼容Config()的viper视图，首次使用时由tree构建
+}
+
+var emptySnapshot = newSnapshot(map[string]any{})
+
+// newSnapshot 由合并后的配置树构建快照，之后不得再修改tree
+func newSnapshot(tree map[string]any) *Snapshot {
+	s := &Snapshot{
+		tree:  tree,
+		index: make(map[string]any),
+	}
+	s.indexInto("", s.tree)
+	sort.Strings(s.leaves)
+	return s
+}
+
+// viper 返回与tree内容一致的viper，增量合并时多数快照不会被Config()读取，因此延迟构建
+func (s *Snapshot) viper() *viper.Viper {
+	s.vpOnce.Do(func() {
+		vp := viper.New()
+		_ = vp.MergeConfigMap(deepCopyMap(s.tree)) // 拷贝一份，viper会改写传入的map
+		s.vp = vp
+	})
+	return s.vp
+}
+
+func (s *Snapshot) indexInto(prefix string, m map[string]any) {
+	for k, v := range m {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		s.index[path] = v
+		if sub, ok := v.(map[string]any); ok && len(sub) > 0 {
+			s.indexInto(path, sub)
+			continue
+		}
+		s.leaves = append(s.leaves, path)
+	}
+}
+
+// Generation 快照的generation，见Bridge.Generation
+func (s *Snapshot) Generation() uint64 {
+	return s.gen
+}
+
+// Get 按"."分隔的路径读取，不区分大小写，不存在时返回nil
+func (s *Snapshot) Get(key string) any {
+	return s.index[strings.ToLower(key)]
+}
+
+func (s *Snapshot) IsSet(key string) bool {
+	_, ok := s.index[strings.ToLower(key)]
+	return ok
+}
+
+// Tree 返回完整的嵌套配置树
+func (s *Snapshot) Tree() map[string]any {
+	return s.tree
+}
+
+// Keys 返回全部叶子节点路径，已排序
+func (s *Snapshot) Keys() []string {
+	return s.leaves
+}
+
+// Range 按路径顺序遍历叶子节点，fn返回false时停止
+func (s *Snapshot) Range(fn func(key string, value any) bool) {
+	for _, k := range s.leaves {
+		if !fn(k, s.index[k]) {
+			return
+		}
+	}
+}
+
+func (s *Snapshot) Len() int {
+	return len(s.leaves)
+}
+
+// Equal 两个快照的叶子节点完全相同
+func (s *Snapshot) Equal(other *Snapshot) bool {
+	if s == other {
+		return true
+	}
+	if len(s.leaves) != len(other.leaves) {
+		return false
+	}
+	for i, k := range s.leaves {
+		if other.leaves[i] != k || !reflect.DeepEqual(s.index[k], other.index[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Diff 返回从s到other的变化，结果按key排序
+func (s *Snapshot) Diff(other *Snapshot) (added, removed, modified []KeyChange) {
+	i, j := 0, 0
+	for i < len(s.leaves) || j < len(other.leaves) {
+		switch {
+		case j >= len(other.leaves) || (i < len(s.leaves) && s.leaves[i] < other.leaves[j]):
+			k := s.leaves[i]
+			removed = append(removed, KeyChange{Key: k, OldValue: s.index[k]})
+			i++
+		case i >= len(s.leaves) || other.leaves[j] < s.leaves[i]:
+			k := other.leaves[j]
+			added = append(added, KeyChange{Key: k, NewValue: other.index[k]})
+			j++
+		default:
+			k := s.leaves[i]
+			if ov, nv := s.index[k], other.index[k]; !reflect.DeepEqual(ov, nv) {
+				modified = append(modified, KeyChange{Key: k, OldValue: ov, NewValue: nv})
+			}
+			i++
+			j++
+		}
+	}
+	return added, removed, modified
+}
@@ -0,0 +1,79 @@
+package confremote_pilot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func snapshotOf(t *testing.T, settings map[string]any) *Snapshot {
+	t.Helper()
+	tree, err := mergeTree(MergeDeep, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newSnapshot(tree)
+}
+
+func TestSnapshot(t *testing.T) {
+	s := snapshotOf(t, map[string]any{
+		"DB":   map[string]any{"host": "h", "port": 1},
+		"tags": []any{"x", "y"},
+	})
+	if got := s.Get("db.HOST"); got != "h" {
+		t.Errorf("Get(db.HOST) = %v", got)
+	}
+	if got, ok := s.Get("db").(map[string]any); !ok || len(got) != 2 {
+		t.Errorf("Get(db) = %v, want subtree", s.Get("db"))
+	}
+	if !s.IsSet("db") || s.IsSet("missing") {
+		t.Error("IsSet mismatch")
+	}
+	if got, want := s.Keys(), []string{"db.host", "db.port", "tags"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys = %v, want %v", got, want)
+	}
+	var n int
+	s.Range(func(key string, value any) bool {
+		n++
+		return key != "db.port"
+	})
+	if n != 2 {
+		t.Errorf("Range visited %d keys before stop, want 2", n)
+	}
+	if !s.Equal(snapshotOf(t, map[string]any{"db": map[string]any{"port": 1, "host": "h"}, "tags": []any{"x", "y"}})) {
+		t.Error("Equal should ignore key order and case")
+	}
+	if s.Equal(emptySnapshot) {
+		t.Error("Equal should detect differences")
+	}
+	if allocs := testing.AllocsPerRun(100, func() { _ = s.Get("db.host") }); allocs != 0 {
+		t.Errorf("Get allocates %v times", allocs)
+	}
+}
+
+func TestBridge_Snapshot(t *testing.T) {
+	b, f := newFakeBridge(t)
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{"name": "a"})); err != nil {
+		t.Fatal(err)
+	}
+	before := b.Snapshot()
+	f.get("a").push(map[string]any{"name": "b"})
+	if before.Get("name") != "a" || b.Snapshot().Get("name") != "b" {
+		t.Error("snapshot must be immutable across updates")
+	}
+	if b.Config().GetString("name") != "b" {
+		t.Error("Config() must follow the latest snapshot")
+	}
+}
+
+func TestBridge_AllCopy(t *testing.T) {
+	b, _ := newFakeBridge(t)
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{"db": map[string]any{"host": "h"}})); err != nil {
+		t.Fatal(err)
+	}
+	all := b.All()
+	all["db"].(map[string]any)["host"] = "changed"
+	delete(all, "db")
+	if b.Get("db.host") != "h" || b.Snapshot().Tree()["db"].(map[string]any)["host"] != "h" {
+		t.Error("mutating All() must not affect the snapshot")
+	}
+}
@@ -0,0 +1,330 @@
+package confremote_pilot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fufuzion/confremote-pilot/logger"
+	"github.com/fufuzion/confremote-pilot/provider"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrChangeNotFound = errors.New("pending change not found")
+
+// ApplyMode 远端变更生效的方式
+type ApplyMode int
+
+const (
+	ApplyImmediate ApplyMode = iota // 立即合并到视图
+	ApplyDelayed                    // 暂存为待生效的变更，Delay后自动合并
+	ApplyApproval                   // 暂存为待生效的变更，调用Approve后合并
+)
+
+// ApplyPolicy 某个key前缀下的变更生效方式
+type ApplyPolicy struct {
+	Mode  ApplyMode
+	Delay time.Duration // Mode为ApplyDelayed时的等待时间
+}
+
+type prefixPolicy struct {
+	prefix []string
+	policy ApplyPolicy
+}
+
+// WithApplyPolicy 为prefix（写法同Filter.Keys）下的key设置远端变更的生效方式，未命中任何前缀的key立即生效。
+// 一次变更涉及多个前缀时整体按最严格的方式暂存：ApplyApproval优先于ApplyDelayed，多个延迟取最长的；
+// 同一source的新变更会替换其尚未生效的变更。各实例可配置不同的策略，如金丝雀实例立即生效、其余实例等待审批
+func WithApplyPolicy(prefix string, policy ApplyPolicy) BridgeOption {
+	return func(o *bridgeOption) {
+		o.applyPolicies = append(o.applyPolicies, prefixPolicy{
+			prefix: strings.Split(strings.ToLower(prefix), "."),
+			policy: policy,
+		})
+	}
+}
+
+// PendingChange 暂存的待生效变更，差异相对暂存时的视图
+type PendingChange struct {
+	ID       string
+	Source   string
+	Provider string
+	Revision string
+	Mode     ApplyMode
+	Created  time.Time
+	ApplyAt  time.Time // Mode为ApplyDelayed时预计生效的时间
+	Added    []KeyChange
+	Removed  []KeyChange
+	Modified []KeyChange
+}
+
+// stagedChange source暂存的变更
+type stagedChange struct {
+	PendingChange
+	settings map[string]any
+	msg      map[string]any // 按到达顺序浅合并的通知内容
+	timer    *time.Timer
+}
+
+// policyFor 返回changes中各key的策略里最严格的一个
+func (b *Bridge) policyFor(changes ...[]KeyChange) ApplyPolicy {
+	var ret ApplyPolicy
+	for _, list := range changes {
+		for _, c := range list {
+			segments := strings.Split(c.Key, ".")
+			for _, pp := range b.o.applyPolicies {
+				if !matchPattern(pp.prefix, segments) {
+					continue
+				}
+				switch {
+				case pp.policy.Mode > ret.Mode:
+					ret = pp.policy
+				case pp.policy.Mode == ret.Mode && pp.policy.Delay > ret.Delay:
+					ret.Delay = pp.policy.Delay
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// stage 重新读取s的配置，按ApplyPolicy立即合并或暂存，调用方需持有b.mu写锁；
+// 立即合并时返回nil，失败时s的缓存与视图均保持不变
+func (b *Bridge) stage(s *source, msg map[string]any) (*PendingChange, error) {
+	setting, err := s.pv.Load()
+	if err != nil {
+		return nil, s.sourceError(provider.OpLoad, err)
+	}
+	return b.stageSettings(s, deepCopyMap(setting), msg)
+}
+
+// stageSettings 同stage，使用已读取的配置
+func (b *Bridge) stageSettings(s *source, settings, msg map[string]any) (*PendingChange, error) {
+	old := s.settings
+	s.settings = settings
+	candidate, err := b.merge(changedKeys(old, s.settings))
+	if err != nil {
+		s.settings = old
+		return nil, err
+	}
+	added, removed, modified := b.Snapshot().Diff(candidate)
+	policy := b.policyFor(added, removed, modified)
+	prev := s.staged
+	b.dropStaged(s)
+	if policy.Mode == ApplyImmediate {
+		if err = b.install(s.key, candidate); err != nil {
+			s.settings = old
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	st := &stagedChange{settings: s.settings, msg: make(map[string]any)}
+	s.settings = old
+	if prev != nil {
+		for k, v := range prev.msg {
+			st.msg[k] = v
+		}
+	}
+	for k, v := range msg {
+		st.msg[k] = v
+	}
+	b.stageSeq++
+	ev := newChangeEvent(EventChanged, s, 0)
+	st.PendingChange = PendingChange{
+		ID:       s.key + "#" + strconv.FormatUint(b.stageSeq, 10),
+		Source:   ev.Source,
+		Provider: ev.Provider,
+		Revision: ev.Revision,
+		Mode:     policy.Mode,
+		Created:  ev.Time,
+		Added:    added,
+		Removed:  removed,
+		Modified: modified,
+	}
+	if policy.Mode == ApplyDelayed {
+		id := st.ID
+		st.ApplyAt = st.Created.Add(policy.Delay)
+		st.timer = time.AfterFunc(policy.Delay, func() {
+			b.enqueue(func() {
+				_ = b.applyStaged(id)
+			})
+		})
+	}
+	s.staged = st
+	pc := st.PendingChange
+	return &pc, nil
+}
+
+// policyOf 返回s的配置替换为settings后相对当前视图的差异所需的策略，不修改s与视图，调用方需持有b.mu写锁
+func (b *Bridge) policyOf(s *source, settings map[string]any) (ApplyPolicy, error) {
+	old := s.settings
+	s.settings = settings
+	candidate, err := b.merge(changedKeys(old, settings))
+	s.settings = old
+	if err != nil {
+		return ApplyPolicy{}, err
+	}
+	return b.policyFor(b.Snapshot().Diff(candidate)), nil
+}
+
+// applyBatch 把各source替换为next中的配置：差异需要延迟或审批的source逐个暂存，其余的一次合并提交，调用方需持有b.mu写锁；
+// approved为true的source已被批准，不再经过ApplyPolicy
+func (b *Bridge) applyBatch(srcs []*source, next, msgs []map[string]any, approved []bool) error {
+	var (
+		now     []*source
+		nowNext []map[string]any
+		nowMsgs []map[string]any
+		errs    []error
+	)
+	for i, s := range srcs {
+		if len(b.o.applyPolicies) > 0 && !approved[i] {
+			if policy, err := b.policyOf(s, next[i]); err != nil || policy.Mode != ApplyImmediate {
+				if err = b.stageOne(s, next[i], msgs[i]); err != nil {
+					errs = append(errs, fmt.Errorf("source %s: %w", s.key, err))
+				}
+				continue
+			}
+		}
+		now = append(now, s)
+		nowNext = append(nowNext, next[i])
+		nowMsgs = append(nowMsgs, msgs[i])
+	}
+	if len(now) > 0 {
+		if err := b.applySources(now, nowNext, nowMsgs); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stageOne 按ApplyPolicy暂存或立即合并s的新配置并提交，调用方需持有b.mu写锁
+func (b *Bridge) stageOne(s *source, settings, msg map[string]any) error {
+	before := b.Snapshot()
+	pc, err := b.stageSettings(s, settings, msg)
+	var verr *ValidationError
+	switch {
+	case pc != nil:
+		b.o.logger.Info("config change staged", logger.KeySource, s.key, logger.KeyRevision, pc.Revision, "change", pc.ID, "mode", pc.Mode)
+	case errors.As(err, &verr):
+		ev := newChangeEvent(EventValidationFailed, s, before.gen)
+		b.enqueue(func() {
+			b.publishRejected(ev, before, verr)
+		})
+	case err == nil:
+		b.commit(s, before, func(*Snapshot) {
+			b.fireHook(s.key, msg)
+		})
+		b.cacheLocked(s)
+	}
+	return err
+}
+
+// dropStaged 丢弃s尚未生效的变更，调用方需持有b.mu写锁
+func (b *Bridge) dropStaged(s *source) {
+	if s.staged == nil {
+		return
+	}
+	if s.staged.timer != nil {
+		s.staged.timer.Stop()
+	}
+	s.staged = nil
+}
+
+// findStaged 返回暂存了id对应变更的source，调用方需持有b.mu
+func (b *Bridge) findStaged(id string) *source {
+	for _, s := range b.pvm {
+		if s.staged != nil && s.staged.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// PendingChanges 返回全部暂存的待生效变更，按暂存顺序排列
+func (b *Bridge) PendingChanges() []PendingChange {
+	b.mu.RLock()
+	ret := make([]PendingChange, 0)
+	for _, s := range b.pvm {
+		if s.staged != nil {
+			ret = append(ret, s.staged.PendingChange)
+		}
+	}
+	b.mu.RUnlock()
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Created.Before(ret[j].Created)
+	})
+	return ret
+}
+
+// Approve 立即合并id对应的暂存变更，不限于ApplyApproval；校验失败时视图保持不变并分发EventValidationFailed
+func (b *Bridge) Approve(changeID string) error {
+	return b.applyStaged(changeID)
+}
+
+// Reject 丢弃id对应的暂存变更，source之后的变更仍按ApplyPolicy处理
+func (b *Bridge) Reject(changeID string) error {
+	b.mu.Lock()
+	s := b.findStaged(changeID)
+	if s == nil {
+		b.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrChangeNotFound, changeID)
+	}
+	b.dropStaged(s)
+	b.mu.Unlock()
+	b.o.logger.Info("pending change rejected", logger.KeySource, s.key, "change", changeID)
+	return nil
+}
+
+// applyStaged 合并暂存的变更；Freeze期间转为冻结暂存，Thaw时生效
+func (b *Bridge) applyStaged(id string) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBridgeClosed
+	}
+	s := b.findStaged(id)
+	if s == nil {
+		b.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrChangeNotFound, id)
+	}
+	st := s.staged
+	b.dropStaged(s)
+	if b.frozen {
+		s.held, s.approved = st.settings, true
+		if s.heldMsg == nil {
+			s.heldMsg = make(map[string]any, len(st.msg))
+		}
+		for k, v := range st.msg {
+			s.heldMsg[k] = v
+		}
+		b.mu.Unlock()
+		b.o.logger.Info("pending change held while frozen", logger.KeySource, s.key, "change", id)
+		return nil
+	}
+	before := b.Snapshot()
+	old := s.settings
+	s.settings = st.settings
+	err := b.remergeSource(s, old)
+	var verr *ValidationError
+	switch {
+	case errors.As(err, &verr):
+		ev := newChangeEvent(EventValidationFailed, s, before.gen)
+		b.enqueue(func() {
+			b.publishRejected(ev, before, verr)
+		})
+	case err == nil:
+		b.commit(s, before, func(*Snapshot) {
+			b.fireHook(s.key, st.msg)
+		})
+		b.cacheLocked(s)
+	}
+	b.mu.Unlock()
+	if err != nil {
+		b.o.logger.Error("apply pending change failed", logger.KeySource, s.key, "change", id, logger.KeyError, err)
+		return err
+	}
+	b.o.logger.Info("pending change applied", logger.KeySource, s.key, "change", id, logger.KeyRevision, st.Revision)
+	return nil
+}
@@ -0,0 +1,89 @@
+package confremote_pilot
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBridge_ApplyPolicyApproval(t *testing.T) {
+	b, f := newFakeBridge(t, WithApplyPolicy("db", ApplyPolicy{Mode: ApplyApproval}))
+	var hooked []map[string]any
+	b.SetHook(func(key string, msg map[string]any) {
+		hooked = append(hooked, msg)
+	})
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{"db": map[string]any{"host": "h0"}, "n": 0})); err != nil {
+		t.Fatal(err)
+	}
+	f.get("a").push(map[string]any{"db": map[string]any{"host": "h0"}, "n": 1})
+	if b.Get("n") != 1 || len(b.PendingChanges()) != 0 {
+		t.Fatalf("change outside the prefix must apply immediately, n = %v", b.Get("n"))
+	}
+	gen := b.Generation()
+	f.get("a").push(map[string]any{"db": map[string]any{"host": "h1"}, "n": 2})
+	pending := b.PendingChanges()
+	if len(pending) != 1 {
+		t.Fatalf("pending = %+v, want one staged change", pending)
+	}
+	pc := pending[0]
+	if pc.Source != "a" || pc.Mode != ApplyApproval || pc.Revision != "2" || len(pc.Modified) != 2 {
+		t.Errorf("pending change = %+v", pc)
+	}
+	if b.Generation() != gen || b.Get("db.host") != "h0" || b.Get("n") != 1 {
+		t.Errorf("staged change went live: %v", b.All())
+	}
+
+	// 新的变更替换尚未生效的变更
+	f.get("a").push(map[string]any{"db": map[string]any{"host": "h2"}, "n": 2})
+	pending = b.PendingChanges()
+	if len(pending) != 1 || pending[0].ID == pc.ID {
+		t.Fatalf("pending = %+v, want the newer change only", pending)
+	}
+	if err := b.Approve(pc.ID); !errors.Is(err, ErrChangeNotFound) {
+		t.Errorf("err = %v, want ErrChangeNotFound", err)
+	}
+	if err := b.Approve(pending[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	b.flush()
+	if b.Get("db.host") != "h2" || b.Get("n") != 2 || len(b.PendingChanges()) != 0 {
+		t.Errorf("tree = %v after Approve", b.All())
+	}
+	if len(hooked) != 2 || len(hooked[1]) != 2 {
+		t.Errorf("hook msgs = %v", hooked)
+	}
+
+	f.get("a").push(map[string]any{"db": map[string]any{"host": "h3"}, "n": 2})
+	if err := b.Reject(b.PendingChanges()[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if b.Get("db.host") != "h2" || len(b.PendingChanges()) != 0 {
+		t.Errorf("rejected change must be discarded, tree = %v", b.All())
+	}
+}
+
+func TestBridge_ApplyPolicyDelayed(t *testing.T) {
+	b, f := newFakeBridge(t,
+		WithApplyPolicy("feature.*", ApplyPolicy{Mode: ApplyDelayed, Delay: 50 * time.Millisecond}),
+		WithApplyPolicy("feature.x", ApplyPolicy{Mode: ApplyDelayed, Delay: 100 * time.Millisecond}),
+	)
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{"feature": map[string]any{"x": false}})); err != nil {
+		t.Fatal(err)
+	}
+	gen := b.Generation()
+	f.get("a").push(map[string]any{"feature": map[string]any{"x": true}})
+	pending := b.PendingChanges()
+	if len(pending) != 1 || pending[0].Mode != ApplyDelayed {
+		t.Fatalf("pending = %+v", pending)
+	}
+	if d := pending[0].ApplyAt.Sub(pending[0].Created); d != 100*time.Millisecond {
+		t.Errorf("delay = %v, want the longest matching delay", d)
+	}
+	if b.Get("feature.x") != false {
+		t.Error("delayed change applied too early")
+	}
+	waitGeneration(t, b, gen+1)
+	if b.Get("feature.x") != true || len(b.PendingChanges()) != 0 {
+		t.Errorf("tree = %v after delay", b.All())
+	}
+}
@@ -0,0 +1,118 @@
+package confremote_pilot
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// OverflowPolicy 事件流缓冲区写满时的处理方式
+type OverflowPolicy int
+
+const (
+	OverflowBlock      OverflowPolicy = iota // 阻塞分发直到消费方读取，或ctx/bridge结束；期间其他订阅方与hook也收不到后续事件
+	OverflowDropOldest                       // 丢弃缓冲区中最旧的事件
+	OverflowResync                           // 清空缓冲区并以一个EventResync事件代替
+)
+
+type streamOption struct {
+	bufferSize int
+	overflow   OverflowPolicy
+}
+
+type StreamOption func(*streamOption)
+
+func WithBufferSize(n int) StreamOption {
+	return func(o *streamOption) {
+		if n > 0 {
+			o.bufferSize = n
+		}
+	}
+}
+func WithOverflow(p OverflowPolicy) StreamOption {
+	return func(o *streamOption) {
+		o.overflow = p
+	}
+}
+
+type stream struct {
+	ctx    context.Context
+	mu     sync.Mutex
+	out    chan ChangeEvent
+	closed bool
+	o      *streamOption
+}
+
+// Events 以channel的形式订阅变更事件，默认缓冲16个事件、写满时以EventResync代替（OverflowResync）；
+// ctx结束或bridge关闭后channel被关闭
+func (b *Bridge) Events(ctx context.Context, filter Filter, opts ...StreamOption) <-chan ChangeEvent {
+	o := &streamOption{
+		bufferSize: 16,
+		overflow:   OverflowResync,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s := &stream{
+		ctx: ctx,
+		out: make(chan ChangeEvent, o.bufferSize),
+		o:   o,
+	}
+	unsubscribe := b.Subscribe(filter, s.send)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-b.ctx.Done():
+		case <-b.done:
+		}
+		cancel()
+		unsubscribe()
+		s.close()
+	}()
+	return s.out
+}
+
+func (s *stream) send(ev ChangeEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.out <- ev:
+		return
+	default:
+	}
+	switch s.o.overflow {
+	case OverflowDropOldest:
+		select {
+		case <-s.out:
+		default:
+		}
+		s.out <- ev // 只有持锁的一方写入，腾出位置后不会阻塞
+	case OverflowResync:
+		// 消费方可能同时在读取，不能以len判断后阻塞接收
+	drain:
+		for {
+			select {
+			case <-s.out:
+			default:
+				break drain
+			}
+		}
+		s.out <- ChangeEvent{Type: EventResync, Generation: ev.Generation, Time: time.Now()}
+	default:
+		select {
+		case s.out <- ev:
+		case <-s.ctx.Done():
+		}
+	}
+}
+
+func (s *stream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	close(s.out)
+}
@@ -0,0 +1,142 @@
+package confremote_pilot
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func recvEvent(t *testing.T, ch <-chan ChangeEvent) (ChangeEvent, bool) {
+	t.Helper()
+	select {
+	case ev, ok := <-ch:
+		return ev, ok
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for event")
+		return ChangeEvent{}, false
+	}
+}
+
+func TestBridge_Events(t *testing.T) {
+	b, f := newFakeBridge(t)
+	if err := b.RegisterSource("a", fakeConfig(nil)); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := b.Events(ctx, Filter{Keys: []string{"name"}})
+	f.get("a").push(map[string]any{"port": 1})
+	f.get("a").push(map[string]any{"port": 1, "name": "a"})
+	ev, ok := recvEvent(t, ch)
+	if !ok || len(ev.Added) != 1 || ev.Added[0].Key != "name" {
+		t.Errorf("event = %+v, want name added", ev)
+	}
+	cancel()
+	if _, ok := recvEvent(t, ch); ok {
+		t.Error("channel should be closed after ctx cancel")
+	}
+}
+
+func TestBridge_EventsOverflow(t *testing.T) {
+	tests := []struct {
+		policy   OverflowPolicy
+		wantName any
+		resync   bool
+	}{
+		{OverflowDropOldest, "v2", false},
+		{OverflowResync, nil, true},
+	}
+	for _, tt := range tests {
+		b, f := newFakeBridge(t)
+		if err := b.RegisterSource("a", fakeConfig(nil)); err != nil {
+			t.Fatal(err)
+		}
+		ch := b.Events(context.Background(), Filter{}, WithBufferSize(2), WithOverflow(tt.policy))
+		for i := 0; i <= 2; i++ {
+			f.get("a").push(map[string]any{"name": "v" + string(rune('0'+i))})
+		}
+		ev, _ := recvEvent(t, ch)
+		if got := ev.Type == EventResync; got != tt.resync {
+			t.Errorf("policy %d: resync = %v, want %v", tt.policy, got, tt.resync)
+		}
+		if !tt.resync {
+			ev, _ = recvEvent(t, ch)
+			if ev.Modified[0].NewValue != tt.wantName {
+				t.Errorf("policy %d: last event name = %v, want %v", tt.policy, ev.Modified[0].NewValue, tt.wantName)
+			}
+		}
+		if err := b.Close(); err != nil {
+			t.Fatal(err)
+		}
+		for range ch {
+		}
+	}
+}
+
+func TestStream_ResyncConcurrentReader(t *testing.T) {
+	s := &stream{
+		ctx: context.Background(),
+		out: make(chan ChangeEvent, 4),
+		o:   &streamOption{bufferSize: 4, overflow: OverflowResync},
+	}
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-s.out:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	defer close(stop)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000000; i++ {
+			s.send(ChangeEvent{Generation: uint64(i)})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("send blocked while a reader drained the buffer")
+	}
+}
+
+func TestBridge_StalledStream(t *testing.T) {
+	b, f := newFakeBridge(t)
+	defer b.Close()
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{"n": 0})); err != nil {
+		t.Fatal(err)
+	}
+	n := b.Int("n", -1)
+	_ = b.Events(context.Background(), Filter{}, WithBufferSize(1), WithOverflow(OverflowBlock)) // 从不读取
+	p := f.get("a")
+	p.flush = b.flushLoop // 订阅方停滞，不等待分发
+	for i := 1; i <= 5; i++ {
+		p.push(map[string]any{"n": i})
+		if got := b.Get("n"); got != i {
+			t.Fatalf("n = %v after push %d, want the stalled stream not to block applying", got, i)
+		}
+		if got := n.Load(); got != i {
+			t.Fatalf("Value = %d after push %d, want %d", got, i, i)
+		}
+	}
+}
+
+func TestBridge_EventsDefaultOverflow(t *testing.T) {
+	b, f := newFakeBridge(t)
+	if err := b.RegisterSource("a", fakeConfig(nil)); err != nil {
+		t.Fatal(err)
+	}
+	ch := b.Events(context.Background(), Filter{}, WithBufferSize(1))
+	for i := 1; i <= 3; i++ {
+		f.get("a").push(map[string]any{"n": i})
+	}
+	if ev, _ := recvEvent(t, ch); ev.Type != EventResync {
+		t.Errorf("event = %+v, want EventResync by default", ev)
+	}
+	_ = b.Close()
+	for range ch {
+	}
+}
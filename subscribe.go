@@ -0,0 +1,162 @@
+package confremote_pilot
+
+import (
+	"strings"
+)
+
+// Filter 订阅过滤条件，各字段为空时不限制
+type Filter struct {
+	// Keys key的前缀或glob，以"."分段且不区分大小写：
+	// 不含通配符时按分段前缀匹配，"db"匹配"db"与"db.host"；
+	// "*"匹配一段，"**"匹配零或多段，如"db.**"、"http.*.timeout"
+	Keys []string
+	// Sources 只关注这些source触发的变更
+	Sources []string
+}
+
+type subscriber struct {
+	id     uint64
+	filter Filter
+	fn     func(ChangeEvent)
+}
+
+// Subscribe 注册变更事件回调，事件中只保留命中filter的key，未命中任何key时不回调；
+// 返回的cancel用于取消订阅，可重复调用
+func (b *Bridge) Subscribe(filter Filter, fn func(ChangeEvent)) (cancel func()) {
+	b.handlerMu.Lock()
+	defer b.handlerMu.Unlock()
+	b.subSeq++
+	sub := &subscriber{id: b.subSeq, filter: normalizeFilter(filter), fn: fn}
+	// copy-on-write，分发时无需持锁
+	subscribers := make([]*subscriber, 0, len(b.subscribers)+1)
+	subscribers = append(subscribers, b.subscribers...)
+	b.subscribers = append(subscribers, sub)
+	return func() {
+		b.unsubscribe(sub.id)
+	}
+}
+
+// OnChange 注册不带过滤条件的变更事件回调
+func (b *Bridge) OnChange(fn func(ChangeEvent)) {
+	b.Subscribe(Filter{}, fn)
+}
+
+func (b *Bridge) unsubscribe(id uint64) {
+	b.handlerMu.Lock()
+	defer b.handlerMu.Unlock()
+	subscribers := make([]*subscriber, 0, len(b.subscribers))
+	for _, sub := range b.subscribers {
+		if sub.id != id {
+			subscribers = append(subscribers, sub)
+		}
+	}
+	b.subscribers = subscribers
+}
+
+func (b *Bridge) dispatch(ev ChangeEvent) {
+	b.handlerMu.RLock()
+	subscribers := b.subscribers
+	b.handlerMu.RUnlock()
+	for _, sub := range subscribers {
+		matched, ok := sub.filter.apply(ev)
+		if !ok {
+			continue
+		}
+		switch b.o.hookDispatch {
+		case HookDispatchAsync:
+			go sub.fn(matched)
+		default:
+			sub.fn(matched)
+		}
+	}
+}
+
+func normalizeFilter(f Filter) Filter {
+	keys := make([]string, 0, len(f.Keys))
+	for _, k := range f.Keys {
+		keys = append(keys, strings.ToLower(k))
+	}
+	return Filter{Keys: keys, Sources: f.Sources}
+}
+
+// apply 返回只包含命中key的事件，没有命中时返回false
+func (f Filter) apply(ev ChangeEvent) (ChangeEvent, bool) {
+	if len(f.Sources) > 0 && !contains(f.Sources, ev.Source) {
+		return ev, false
+	}
+	if len(f.Keys) == 0 {
+		return ev, true
+	}
+	ev.Added = f.keep(ev.Added)
+	ev.Removed = f.keep(ev.Removed)
+	ev.Modified = f.keep(ev.Modified)
+	return ev, !ev.Empty()
+}
+
+func (f Filter) keep(changes []KeyChange) []KeyChange {
+	var ret []KeyChange
+	for _, c := range changes {
+		if f.Match(c.Key) {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// Match 判断key是否命中Filter.Keys，Keys为空时总是命中
+func (f Filter) Match(key string) bool {
+	if len(f.Keys) == 0 {
+		return true
+	}
+	segments := strings.Split(strings.ToLower(key), ".")
+	for _, pattern := range f.Keys {
+		if matchPattern(strings.Split(strings.ToLower(pattern), "."), segments) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPattern(pattern, segments []string) bool {
+	if !strings.Contains(strings.Join(pattern, "."), "*") {
+		// 无通配符时按分段前缀匹配
+		if len(pattern) > len(segments) {
+			return false
+		}
+		for i := range pattern {
+			if pattern[i] != segments[i] {
+				return false
+			}
+		}
+		return true
+	}
+	return matchGlob(pattern, segments)
+}
+
+func matchGlob(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	switch pattern[0] {
+	case "**":
+		for i := 0; i <= len(segments); i++ {
+			if matchGlob(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(segments) > 0 && matchGlob(pattern[1:], segments[1:])
+	default:
+		return len(segments) > 0 && pattern[0] == segments[0] && matchGlob(pattern[1:], segments[1:])
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package confremote_pilot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilter_Match(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"db", "db", true},
+		{"db", "db.host", true},
+		{"db", "dbx.host", false},
+		{"DB.Host", "db.host", true},
+		{"db.**", "db", true},
+		{"db.**", "db.master.host", true},
+		{"db.*", "db.host", true},
+		{"db.*", "db.master.host", false},
+		{"http.*.timeout", "http.client.timeout", true},
+		{"http.*.timeout", "http.timeout", false},
+		{"**.timeout", "http.client.timeout", true},
+	}
+	for _, tt := range tests {
+		f := Filter{Keys: []string{tt.pattern}}
+		if got := f.Match(tt.key); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestBridge_Subscribe(t *testing.T) {
+	b, f := newFakeBridge(t)
+	if err := b.RegisterSource("a", fakeConfig(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RegisterSource("b", fakeConfig(nil)); err != nil {
+		t.Fatal(err)
+	}
+	var dbKeys [][]string
+	cancel := b.Subscribe(Filter{Keys: []string{"db.**"}}, func(ev ChangeEvent) {
+		dbKeys = append(dbKeys, ev.Keys())
+	})
+	var fromB int
+	b.Subscribe(Filter{Sources: []string{"b"}}, func(ev ChangeEvent) {
+		fromB++
+	})
+
+	f.get("a").push(map[string]any{"db": map[string]any{"host": "h"}, "name": "a"})
+	f.get("a").push(map[string]any{"db": map[string]any{"host": "h"}, "name": "a2"})
+	f.get("b").push(map[string]any{"port": 1})
+	if want := [][]string{{"db.host"}}; !reflect.DeepEqual(dbKeys, want) {
+		t.Errorf("db subscriber got %v, want %v", dbKeys, want)
+	}
+	if fromB != 1 {
+		t.Errorf("source subscriber called %d times, want 1", fromB)
+	}
+
+	cancel()
+	cancel()
+	f.get("a").push(map[string]any{"db": map[string]any{"host": "h2"}})
+	if len(dbKeys) != 1 {
+		t.Errorf("cancelled subscriber still called: %v", dbKeys)
+	}
+}
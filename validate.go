@@ -0,0 +1,48 @@
+package confremote_pilot
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+)
+
+// Validator 在新的合并视图生效前校验，返回error时拒绝newCfg并保留oldCfg
+type Validator func(newCfg, oldCfg *viper.Viper) error
+
+// ValidationError 候选配置未通过Validator
+type ValidationError struct {
+	Source    string // 触发本次合并的source key
+	Err       error
+	candidate *Snapshot
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("validate config from source %s: %v", e.Source, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func WithValidator(v Validator) BridgeOption {
+	return func(o *bridgeOption) {
+		o.validators = append(o.validators, v)
+	}
+}
+
+// AddValidator 追加Validator，只对之后的合并生效
+func (b *Bridge) AddValidator(v Validator) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.validators = append(b.validators, v)
+}
+
+// validate 依次运行全部Validator，调用方需持有b.mu
+func (b *Bridge) validate(key string, candidate *Snapshot) error {
+	current := b.Config()
+	for _, v := range b.validators {
+		if err := v(candidate.viper(), current); err != nil {
+			return &ValidationError{Source: key, Err: err, candidate: candidate}
+		}
+	}
+	return nil
+}
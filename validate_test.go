@@ -0,0 +1,56 @@
+package confremote_pilot
+
+import (
+	"errors"
+	"github.com/spf13/viper"
+	"testing"
+)
+
+func requirePort(newCfg, oldCfg *viper.Viper) error {
+	if newCfg.GetInt("port") <= 0 {
+		return errors.New("port must be positive")
+	}
+	return nil
+}
+
+func TestBridge_Validator(t *testing.T) {
+	b, f := newFakeBridge(t, WithValidator(requirePort))
+	if err := b.RegisterSource("bad", fakeConfig(map[string]any{"name": "x"})); err == nil {
+		t.Fatal("RegisterSource with invalid config should fail")
+	}
+	if len(b.Layers()) != 0 {
+		t.Fatal("rejected source must not stay registered")
+	}
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{"port": 80})); err != nil {
+		t.Fatal(err)
+	}
+	var failed []ChangeEvent
+	b.OnChange(func(ev ChangeEvent) {
+		if ev.Type == EventValidationFailed {
+			failed = append(failed, ev)
+		}
+	})
+
+	f.get("a").push(map[string]any{"port": 0})
+	if got := b.Config().GetInt("port"); got != 80 {
+		t.Errorf("port = %d, want last good 80", got)
+	}
+	if len(failed) != 1 || failed[0].Source != "a" || failed[0].Err == nil {
+		t.Fatalf("validation failed events = %+v", failed)
+	}
+	if m := failed[0].Modified; len(m) != 1 || m[0].Key != "port" || m[0].NewValue != 0 {
+		t.Errorf("rejected diff = %+v", m)
+	}
+
+	var verr *ValidationError
+	if err := b.UnregisterSource("a"); !errors.As(err, &verr) || verr.Source != "a" {
+		t.Errorf("UnregisterSource err = %v, want *ValidationError", err)
+	}
+	if len(b.Layers()) != 1 {
+		t.Error("source must stay registered when its removal is rejected")
+	}
+	f.get("a").push(map[string]any{"port": 8080})
+	if got := b.Config().GetInt("port"); got != 8080 {
+		t.Errorf("port = %d, want 8080", got)
+	}
+}
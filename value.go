@@ -0,0 +1,147 @@
+package confremote_pilot
+
+import (
+	"github.com/fufuzion/confremote-pilot/logger"
+	"github.com/spf13/cast"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Value 单个key的类型化句柄：创建时解析一次，之后只在该key（或其子树）变化时由bridge更新，
+// Load只有一次原子读；key不存在或转换失败时使用默认值
+type Value[T any] struct {
+	b       *Bridge
+	key     string
+	def     T
+	conv    func(any) (T, error)
+	v       atomic.Pointer[T]
+	mu      sync.Mutex
+	gen     uint64 // 最近一次刷新所用视图的generation
+	changed chan struct{}
+}
+
+type valueUpdater interface {
+	refresh(snap *Snapshot)
+}
+
+// NewValue 创建自定义转换的Value，conv的输入为合并视图中key对应的原始值
+func NewValue[T any](b *Bridge, key string, def T, conv func(any) (T, error)) *Value[T] {
+	v := &Value[T]{
+		b:       b,
+		key:     strings.ToLower(key),
+		def:     def,
+		conv:    conv,
+		changed: make(chan struct{}),
+	}
+	b.valueMu.Lock()
+	b.values[v.key] = append(b.values[v.key], v)
+	b.valueMu.Unlock()
+	v.refresh(b.Snapshot())
+	return v
+}
+
+func (b *Bridge) Int(key string, def int) *Value[int] {
+	return NewValue(b, key, def, cast.ToIntE)
+}
+func (b *Bridge) Int64(key string, def int64) *Value[int64] {
+	return NewValue(b, key, def, cast.ToInt64E)
+}
+func (b *Bridge) Float64(key string, def float64) *Value[float64] {
+	return NewValue(b, key, def, cast.ToFloat64E)
+}
+func (b *Bridge) Bool(key string, def bool) *Value[bool] {
+	return NewValue(b, key, def, cast.ToBoolE)
+}
+func (b *Bridge) String(key string, def string) *Value[string] {
+	return NewValue(b, key, def, cast.ToStringE)
+}
+func (b *Bridge) Duration(key string, def time.Duration) *Value[time.Duration] {
+	return NewValue(b, key, def, cast.ToDurationE)
+}
+func (b *Bridge) StringSlice(key string, def []string) *Value[[]string] {
+	return NewValue(b, key, def, cast.ToStringSliceE)
+}
+
+func (v *Value[T]) Load() T {
+	return *v.v.Load()
+}
+
+func (v *Value[T]) Key() string {
+	return v.key
+}
+
+// Changed 返回在值下一次变化时关闭的channel，收到通知后需重新调用Changed以等待再下一次变化
+func (v *Value[T]) Changed() <-chan struct{} {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.changed
+}
+
+// Close 停止跟随配置更新，Load仍返回最后的值
+func (v *Value[T]) Close() {
+	v.b.valueMu.Lock()
+	defer v.b.valueMu.Unlock()
+	values := v.b.values[v.key]
+	for i, u := range values {
+		if u == valueUpdater(v) {
+			v.b.values[v.key] = append(values[:i:i], values[i+1:]...)
+			break
+		}
+	}
+	if len(v.b.values[v.key]) == 0 {
+		delete(v.b.values, v.key)
+	}
+}
+
+func (v *Value[T]) refresh(snap *Snapshot) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	// 创建时已读取了较新的视图，忽略事件循环中排在其前面的分发
+	if v.v.Load() != nil && snap.gen <= v.gen {
+		return
+	}
+	v.gen = snap.gen
+	next := v.def
+	if snap.IsSet(v.key) {
+		converted, err := v.conv(snap.Get(v.key))
+		if err != nil {
+			v.b.o.logger.Warn("convert value failed, keep previous", logger.KeyPath, v.key, logger.KeyError, err)
+			if v.v.Load() != nil {
+				return
+			}
+		} else {
+			next = converted
+		}
+	}
+	v.v.Store(&next)
+	close(v.changed)
+	v.changed = make(chan struct{})
+}
+
+// updateValues 以snap刷新受ev影响的Value：变化的key本身或其任一上级路径上注册的Value
+func (b *Bridge) updateValues(ev ChangeEvent, snap *Snapshot) {
+	b.valueMu.RLock()
+	if len(b.values) == 0 {
+		b.valueMu.RUnlock()
+		return
+	}
+	affected := make(map[valueUpdater]struct{})
+	for _, key := range ev.Keys() {
+		for path := key; ; {
+			for _, u := range b.values[path] {
+				affected[u] = struct{}{}
+			}
+			i := strings.LastIndexByte(path, '.')
+			if i < 0 {
+				break
+			}
+			path = path[:i]
+		}
+	}
+	b.valueMu.RUnlock()
+	for u := range affected {
+		u.refresh(snap)
+	}
+}
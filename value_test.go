@@ -0,0 +1,66 @@
+package confremote_pilot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBridge_Value(t *testing.T) {
+	b, f := newFakeBridge(t)
+	if err := b.RegisterSource("a", fakeConfig(map[string]any{
+		"http": map[string]any{"timeout_ms": 300},
+		"name": "a",
+	})); err != nil {
+		t.Fatal(err)
+	}
+	timeout := b.Int("HTTP.timeout_ms", 500)
+	retry := b.Duration("http.retry", time.Second)
+	if got := timeout.Load(); got != 300 {
+		t.Errorf("timeout = %d, want 300", got)
+	}
+	if got := retry.Load(); got != time.Second {
+		t.Errorf("retry = %v, want default 1s", got)
+	}
+
+	changed := timeout.Changed()
+	retryChanged := retry.Changed()
+	f.get("a").push(map[string]any{
+		"http": map[string]any{"timeout_ms": 300},
+		"name": "b",
+	})
+	select {
+	case <-changed:
+		t.Error("timeout notified although http.timeout_ms did not change")
+	default:
+	}
+
+	f.get("a").push(map[string]any{
+		"http": map[string]any{"timeout_ms": "800", "retry": "2s"},
+	})
+	select {
+	case <-changed:
+	default:
+		t.Error("timeout change not notified")
+	}
+	<-retryChanged
+	if got := timeout.Load(); got != 800 {
+		t.Errorf("timeout = %d, want 800", got)
+	}
+	if got := retry.Load(); got != 2*time.Second {
+		t.Errorf("retry = %v, want 2s", got)
+	}
+
+	f.get("a").push(map[string]any{"http": map[string]any{"timeout_ms": "bad", "retry": "2s"}})
+	if got := timeout.Load(); got != 800 {
+		t.Errorf("timeout after bad value = %d, want previous 800", got)
+	}
+
+	timeout.Close()
+	f.get("a").push(map[string]any{})
+	if got := timeout.Load(); got != 800 {
+		t.Errorf("closed value updated to %d", got)
+	}
+	if got := retry.Load(); got != time.Second {
+		t.Errorf("retry after removal = %v, want default 1s", got)
+	}
+}